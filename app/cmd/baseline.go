@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/integrity"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+	"time"
+)
+
+/*
+	runBaseline
+
+- baseline create: hash every file the watch targets track and save it as the approved baseline
+- baseline verify: hash the targets again and print every file that diverges
+- returns the process exit code, 1 when verify finds violations
+*/
+func (app *application) runBaseline(args []string) int {
+	if len(args) == 0 {
+		app.errorLog.Println("usage: baseline create|verify")
+		return 2
+	}
+
+	switch args[0] {
+	case "create":
+		algorithm := app.config.HashAlgorithm
+		if algorithm == "" {
+			algorithm = filetrack.HashSHA256
+		}
+
+		baseline, err := integrity.Create(app.config.Watches, algorithm)
+		if err != nil {
+			app.errorLog.Println(err)
+			return 1
+		}
+
+		if err := baseline.Save(app.config.BaselineFile); err != nil {
+			app.errorLog.Println(err)
+			return 1
+		}
+
+		app.infoLog.Printf("Baseline of %d files saved to %s\n", len(baseline.Files), app.config.BaselineFile)
+		return 0
+	case "verify":
+		baseline, err := integrity.Load(app.config.BaselineFile)
+		if err != nil {
+			app.errorLog.Println(err)
+			return 1
+		}
+
+		violations, err := baseline.Verify(app.config.Watches)
+		if err != nil {
+			app.errorLog.Println(err)
+			return 1
+		}
+
+		if len(violations) == 0 {
+			app.infoLog.Printf("All %d files match the baseline\n", len(baseline.Files))
+			return 0
+		}
+
+		js, err := app.JSON(violations)
+		if err != nil {
+			app.errorLog.Println(err)
+			return 1
+		}
+		fmt.Print(string(js))
+		return 1
+	default:
+		app.errorLog.Printf("unknown baseline command: %s\n", args[0])
+		return 2
+	}
+}
+
+// loadBaseline - load the approved baseline if one was created, the tracker must hash with its algorithm
+func (app *application) loadBaseline() error {
+	baseline, err := integrity.Load(app.config.BaselineFile)
+	if err != nil {
+		if errors.Is(err, integrity.ErrNoBaseline) {
+			return nil
+		}
+		return err
+	}
+
+	if app.config.HashAlgorithm != baseline.Algorithm {
+		app.infoLog.Printf("Hashing with %s to match the baseline\n", baseline.Algorithm)
+		app.config.HashAlgorithm = baseline.Algorithm
+	}
+
+	app.baseline = baseline
+	return nil
+}
+
+// checkIntegrity - integrity violation event when the file hash diverges from the baseline
+func (app *application) checkIntegrity(info filetrack.FileInfo) *snapshot.Event {
+	if app.baseline == nil || info.Hash == "" {
+		return nil
+	}
+
+	violation := app.baseline.Check(info.Path, info.Hash)
+	if violation == nil {
+		return nil
+	}
+
+	return &snapshot.Event{
+		Type:         snapshot.IntegrityViolation,
+		Time:         time.Now(),
+		FileInfo:     info,
+		ExpectedHash: violation.Expected,
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxBatchRequestBytes - largest POST /execute/batch body accepted
+const maxBatchRequestBytes = 4 << 20
+
+// batchItem - one command of POST /execute/batch
+type batchItem struct {
+	Command string            `json:"command"`
+	Params  map[string]string `json:"params"`
+}
+
+// batchResult - outcome of one batch item, status is the http status /execute would have answered with
+type batchResult struct {
+	Command string      `json:"command"`
+	Status  int         `json:"status"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+}
+
+/*
+	executeBatchHandler
+
+- runs a json list of {command, params} items like /execute, at most execute_concurrency at a time
+- every item is checked on its own, a denied or failed item does not stop the others
+- the results are returned in the order of the items with the status /execute would have returned
+*/
+func (app *application) executeBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var items []batchItem
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&items); err != nil {
+		app.badRequest(w, r, fmt.Errorf("invalid batch request: %w", err))
+		return
+	}
+
+	cfg := app.currentConfig()
+	if len(items) == 0 || len(items) > cfg.ExecuteBatchMax {
+		app.badRequest(w, r, fmt.Errorf("a batch needs between 1 and %d items", cfg.ExecuteBatchMax))
+		return
+	}
+
+	results := make([]batchResult, len(items))
+	slots := make(chan struct{}, cfg.ExecuteConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, item batchItem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i] = app.runBatchItem(r, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	if err := app.writeJSON(w, http.StatusOK, results, nil); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// runBatchItem - run one item with the checks of /execute
+func (app *application) runBatchItem(r *http.Request, item batchItem) batchResult {
+	result := batchResult{Command: strings.ToUpper(item.Command)}
+
+	info, ok := app.service.CommandRunFile.Lookup(item.Command)
+	if !ok {
+		result.Status, result.Error = http.StatusBadRequest, fmt.Sprintf("unknown command: %s", result.Command)
+		return result
+	}
+	if !app.hasRole(r, info.Role) {
+		result.Status, result.Error = http.StatusForbidden, "your api key does not have permission to run this command"
+		return result
+	}
+
+	// a client that went away does not need the rest of the batch
+	if err := r.Context().Err(); err != nil {
+		result.Status, result.Error = http.StatusServiceUnavailable, err.Error()
+		return result
+	}
+
+	data, err := app.service.CommandRunFile.ExecuteCommand(r.Context(), info.Name, item.Params)
+	if err != nil {
+		result.Status, result.Code = commandErrorStatus(err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status, result.Result = http.StatusOK, data
+	return result
+}
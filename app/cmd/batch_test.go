@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service"
+)
+
+// TestExecuteBatchHandler - every item is checked and answered on its own, in the order of the request
+func TestExecuteBatchHandler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := config.Config{AllowedRoots: []config.AllowedRoot{{Path: dir}}, ExecuteConcurrency: 2, ExecuteBatchMax: 5}
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		config:   cfg,
+		service:  service.NewService(cfg, "", nil),
+	}
+
+	routes := app.routes()
+	request := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(method, "/execute/batch", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := request(http.MethodPost, `[
+		{"command": "CHECK_FILE_DATES", "params": {"path": "/etc/passwd"}},
+		{"command": "NOPE"},
+		{"command": "check_file_dates"},
+		{"command": "check_file_dates", "params": {"path": "`+filepath.ToSlash(file)+`"}}
+	]`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var results []batchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	expected := []struct {
+		command string
+		status  int
+		code    string
+	}{
+		{"CHECK_FILE_DATES", http.StatusForbidden, "outside_allowed_roots"},
+		{"NOPE", http.StatusBadRequest, ""},
+		{"CHECK_FILE_DATES", http.StatusBadRequest, ""},
+	}
+	for i, want := range expected {
+		got := results[i]
+		if got.Command != want.command || got.Status != want.status || got.Code != want.code || got.Error == "" {
+			t.Errorf("item %d: got %+v; want %s %d %q with an error", i, got, want.command, want.status, want.code)
+		}
+	}
+
+	// the allowed file runs whether or not osquery is installed here
+	if last := results[3]; last.Status != http.StatusOK && last.Status != http.StatusBadRequest {
+		t.Errorf("Expected the allowed file to run, got %+v", last)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		expected int
+	}{
+		{"empty batch", http.MethodPost, `[]`, http.StatusBadRequest},
+		{"too many items", http.MethodPost, "[" + strings.Repeat(`{"command": "NOPE"},`, 5) + `{"command": "NOPE"}]`, http.StatusBadRequest},
+		{"not a list", http.MethodPost, `{"command": "NOPE"}`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, `[{"command": "NOPE", "path": "/tmp"}]`, http.StatusBadRequest},
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		if rr := request(test.method, test.body); rr.Code != test.expected {
+			t.Errorf("%s: got status %d; want %d (%s)", test.name, rr.Code, test.expected, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+	runHeadless
+
+- runs the http api, worker, timer and delivery threads without the GUI, for servers and containers
+- the process id is written to pidFile and removed again on exit
+- SIGINT and SIGTERM shut down the server and stop the service through serveHttp
+*/
+func (app *application) runHeadless(pidFile string) error {
+	if err := writePIDFile(pidFile); err != nil {
+		return err
+	}
+	defer os.Remove(pidFile)
+
+	// the logs otherwise shown in the window go to stdout
+	go app.printLogs()
+
+	app.infoLog.Printf("Running headless, pid %d\n", os.Getpid())
+	return app.serveHttp()
+}
+
+// writePIDFile - write the process id, replacing a file left by a process that did not exit cleanly.
+// A file naming a process that is still running means another tracker uses it, so that is an error.
+func writePIDFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("pid file %s names running process %d, is the tracker already running?", path, pid)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing pid file: %w", err)
+	}
+
+	return nil
+}
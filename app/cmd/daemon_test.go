@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestWritePIDFile - the pid file holds the process id
+func TestWritePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filetracker.pid")
+	if err := writePIDFile(path); err != nil {
+		t.Fatalf("writePIDFile returned an error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read pid file: %v", err)
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err != nil || pid != os.Getpid() {
+		t.Errorf("Expected pid %d, got %q", os.Getpid(), data)
+	}
+}
+
+// TestWritePIDFileRunning - a pid file naming a running process is not replaced, a stale one is
+func TestWritePIDFileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filetracker.pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	if err := writePIDFile(path); err == nil {
+		t.Error("Expected a pid file naming a running process to be an error")
+	}
+
+	if err := os.WriteFile(path, []byte("not a pid\n"), 0644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	if err := writePIDFile(path); err != nil {
+		t.Errorf("Expected a stale pid file to be replaced, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/thespider911/filetrackermodification/app/internal/service/outbox"
+	"syscall"
+	"time"
+)
+
+/*
+	deliveryThread
+
+- sends the payloads waiting in the outbox to their api endpoint, batched per batch_format
+- failed deliveries are retried with exponential backoff, the outbox dead-letters them after outbox_max_attempts
+- the outbox is a file so undelivered payloads survive a restart
+*/
+func (app *application) deliveryThread() error {
+	defer app.appendLog("Delivery thread stopped\n")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			app.deliverOutbox()
+		case <-app.serviceStopper:
+			return nil
+		}
+	}
+}
+
+// deliverOutbox - post the due items batch by batch, stopping at the first failure since the endpoint is likely down
+func (app *application) deliverOutbox() {
+	cfg := app.currentConfig()
+	maxRecords, maxWait := cfg.BatchMaxRecords, time.Duration(cfg.BatchMaxWait)*time.Second
+	if cfg.BatchFormat == batchSingle {
+		maxRecords, maxWait = 1, 0
+	}
+
+	for {
+		items, err := app.outbox.Due(time.Now(), maxRecords)
+		if err != nil {
+			app.errorLog.Printf("Error reading outbox: %v\n", err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+
+		// a batch goes to a single endpoint, it ends at the first record for another one so none overtakes it
+		due := len(items)
+		items = sameEndpoint(items)
+
+		// wait for a full batch until the oldest record has waited long enough, a batch cut by another
+		// endpoint cannot grow
+		if len(items) == due && len(items) < maxRecords && time.Since(items[0].Created) < maxWait {
+			return
+		}
+
+		batch := app.limitBatch(items)
+		if err := app.deliverBatch(batch); err != nil {
+			return
+		}
+	}
+}
+
+// limitBatch - cut the batch at batch_max_bytes, always keeping at least one record
+func (app *application) limitBatch(items []outbox.Item) []outbox.Item {
+	maxBytes := app.currentConfig().BatchMaxBytes
+	size := 0
+	for i, item := range items {
+		size += len(item.Payload)
+		if i > 0 && maxBytes > 0 && size > maxBytes {
+			return items[:i]
+		}
+	}
+
+	return items
+}
+
+// sameEndpoint - the leading items going to the same endpoint as the first one
+func sameEndpoint(items []outbox.Item) []outbox.Item {
+	for i, item := range items {
+		if item.Endpoint != items[0].Endpoint {
+			return items[:i]
+		}
+	}
+
+	return items
+}
+
+// deliverBatch - post a batch and acknowledge it, or record the failed attempt
+func (app *application) deliverBatch(batch []outbox.Item) error {
+	records := make([]json.RawMessage, 0, len(batch))
+	ids := make([]uint64, 0, len(batch))
+	for _, item := range batch {
+		records = append(records, item.Payload)
+		ids = append(ids, item.ID)
+	}
+
+	// records queued before watch targets had their own endpoint go to the global one
+	endpoint := batch[0].Endpoint
+	if endpoint == "" {
+		endpoint = app.currentConfig().APIEndpoint
+	}
+
+	body, contentType, err := app.encodeBatch(records)
+	if err == nil {
+		err = app.postToAPI(endpoint, body, contentType)
+	}
+	if err != nil {
+		// if the api is not running
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			app.errorLog.Println("API service not running")
+		} else {
+			app.errorLog.Printf("Error sending to API: %v\n", err)
+		}
+
+		dead, failErr := app.outbox.Fail(batch, err)
+		if failErr != nil {
+			app.errorLog.Printf("Error updating outbox: %v\n", failErr)
+		}
+		for _, id := range dead {
+			app.errorLog.Printf("Outbox item %d dead-lettered after %d attempts\n", id, app.currentConfig().OutboxMaxAttempts)
+		}
+		return err
+	}
+
+	if err := app.outbox.Ack(ids...); err != nil {
+		app.errorLog.Printf("Error updating outbox: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/outbox"
+)
+
+// TestDeliverOutboxOrder - a batch stops at the first record for another endpoint so no record overtakes an earlier one
+func TestDeliverOutboxOrder(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	endpoint := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			received = append(received, name+" "+string(body))
+			mu.Unlock()
+		}))
+	}
+	a, b := endpoint("a"), endpoint("b")
+	defer a.Close()
+	defer b.Close()
+
+	box, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"), outbox.Options{MaxAttempts: 3})
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	defer box.Close()
+
+	for _, item := range []struct{ payload, endpoint string }{
+		{`"a1"`, a.URL}, {`"b1"`, b.URL}, {`"a2"`, a.URL}, {`"a3"`, a.URL},
+	} {
+		if err := box.Enqueue([]byte(item.payload), item.endpoint); err != nil {
+			t.Fatalf("Failed to enqueue %s: %v", item.payload, err)
+		}
+	}
+
+	app := &application{
+		errorLog:   log.New(io.Discard, "", 0),
+		httpClient: http.DefaultClient,
+		outbox:     box,
+		config:     config.Config{BatchFormat: batchJSON, BatchMaxRecords: 10, BatchMaxWait: 60},
+	}
+	app.deliverOutbox()
+
+	// a batch cut by the next endpoint goes at once, the last one waits to fill up
+	expected := []string{`a ["a1"]`, `b ["b1"]`}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected batches %q, got %q", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("batch %d: got %s; want %s", i, received[i], expected[i])
+		}
+	}
+
+	if stats, err := box.Stats(); err != nil || stats.Depth != 2 {
+		t.Errorf("Expected a2 and a3 to wait in the outbox, got %+v (%v)", stats, err)
+	}
+}
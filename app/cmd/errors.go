@@ -1,14 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
 	"net/http"
 	"strings"
 )
 
 // errorMessage - log error as readable text
 func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, message string, headers http.Header) {
-	http.Error(w, http.StatusText(status), status)
-
 	message = strings.ToUpper(message[:1]) + message[1:]
 
 	err := app.writeJSON(w, status, map[string]string{"Error": message}, headers)
@@ -36,3 +37,55 @@ func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
 func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err error) {
 	app.errorMessage(w, r, http.StatusBadRequest, err.Error(), nil)
 }
+
+// unauthorized - missing or unknown api key
+func (app *application) unauthorized(w http.ResponseWriter, r *http.Request) {
+	message := "a valid api key is required"
+	app.errorMessage(w, r, http.StatusUnauthorized, message, http.Header{"WWW-Authenticate": {"Bearer"}})
+}
+
+// forbidden - the api key does not have the role the endpoint needs
+func (app *application) forbidden(w http.ResponseWriter, r *http.Request) {
+	message := "your api key does not have permission to access this resource"
+	app.errorMessage(w, r, http.StatusForbidden, message, nil)
+}
+
+// methodNotAllowed - the endpoint does not take the request method
+func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+	app.errorMessage(w, r, http.StatusMethodNotAllowed, message, http.Header{"Allow": {strings.Join(allowed, ", ")}})
+}
+
+// commandError - a failed /execute command, a denied path gets its own status and code
+func (app *application) commandError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := commandErrorStatus(err)
+	if code == "" {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	message := err.Error()
+	message = strings.ToUpper(message[:1]) + message[1:]
+
+	if err := app.writeJSON(w, status, map[string]string{"Error": message, "Code": code}, nil); err != nil {
+		app.errorLog.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// commandErrorStatus - http status and access error code of a failed command, the code is empty when the path was not denied
+func commandErrorStatus(err error) (int, string) {
+	var accessErr *command.AccessError
+	if !errors.As(err, &accessErr) {
+		return http.StatusBadRequest, ""
+	}
+
+	switch accessErr.Code {
+	case command.CodeInvalidPath:
+		return http.StatusBadRequest, accessErr.Code
+	case command.CodePathNotFound:
+		return http.StatusNotFound, accessErr.Code
+	default:
+		return http.StatusForbidden, accessErr.Code
+	}
+}
@@ -0,0 +1,108 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	fyneapp "fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"image/color"
+	"os"
+)
+
+// guiAvailable - this build has the window, build with the nogui tag to leave out fyne and its cgo dependencies
+const guiAvailable = true
+
+// customTheme - theme with black background and white text
+type customTheme struct{}
+
+var _ fyne.Theme = (*customTheme)(nil)
+
+func (m customTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return theme.DefaultTheme().Icon(name)
+}
+
+func (m customTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if name == theme.ColorNameBackground {
+		if variant == theme.VariantLight {
+			return color.White
+		}
+		return color.Black
+	}
+
+	return theme.DefaultTheme().Color(name, variant)
+}
+
+func (m customTheme) Font(style fyne.TextStyle) fyne.Resource {
+	return theme.DefaultTheme().Font(style)
+}
+
+func (m customTheme) Size(name fyne.ThemeSizeName) float32 {
+	return theme.DefaultTheme().Size(name)
+}
+
+/*
+	runGUI
+
+- the window is a client of the same core as headless mode
+- start and stop the service with the buttons, the logs are shown as they come in
+- the http api runs next to the window until the process gets SIGINT or SIGTERM
+*/
+func (app *application) runGUI() error {
+	// Create Fyne application
+	myApp := fyneapp.New()
+	myApp.Settings().SetTheme(&customTheme{}) // custom theme
+	myWindow := myApp.NewWindow("File Tracker Service")
+
+	// Create UI elements
+	logs := widget.NewMultiLineEntry()
+	logs.Disable()
+
+	startButton := widget.NewButton("Start Service", func() {
+		if !app.isRunning {
+			app.startService()
+		}
+	})
+
+	stopButton := widget.NewButton("Stop Service", func() {
+		if app.isRunning {
+			app.stopService()
+		}
+	})
+
+	buttons := container.NewHBox(startButton, stopButton)
+	content := container.NewBorder(buttons, nil, nil, nil, logs)
+
+	// Set up window
+	myWindow.SetContent(content)
+	myWindow.Resize(fyne.NewSize(600, 400))
+
+	// Start log update goroutine
+	go app.updateLogs(logs)
+
+	// start HTTP server
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		if err := app.serveHttp(); err != nil {
+			app.errorLog.Println(err)
+			os.Exit(0)
+		}
+	}()
+
+	// Run the UI
+	myWindow.ShowAndRun()
+
+	app.wg.Wait()
+	return nil
+}
+
+// updateLogs - to the fynne UI
+func (app *application) updateLogs(logs *widget.Entry) {
+	for text := range app.logChan {
+		logs.SetText(logs.Text + text)
+		logs.CursorRow = len(logs.Text)
+	}
+}
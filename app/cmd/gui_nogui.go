@@ -0,0 +1,13 @@
+//go:build nogui
+
+package main
+
+import "errors"
+
+// guiAvailable - this build has no window, it runs headless unless told otherwise
+const guiAvailable = false
+
+// runGUI - the window is left out of this build
+func (app *application) runGUI() error {
+	return errors.New("this build has no GUI, it was built with the nogui tag: run it with --headless")
+}
@@ -1,59 +1,42 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// CommandInfo - to stores information about each command
-type CommandInfo struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Usage       string `json:"usage"`
-}
-
-// --------------- COMMANDS --------------- //
-var commandInfoMap = map[string]CommandInfo{
-	"CHECK_DIRECTORY_FILE": {
-		Name:        "CHECK_DIRECTORY_FILE",
-		Description: "Checks file information for a given file path",
-		Usage:       "/execute?command=CHECK_DIRECTORY_FILE&path=/path/to/file",
-	},
-	"CHECK_FILE_PERMISSION": {
-		Name:        "CHECK_FILE_PERMISSION",
-		Description: "Checks file permission of a given file",
-		Usage:       "/execute?command=CHECK_FILE_PERMISSION&path=/path/to/file",
-	},
-	"CHECK_FILE_TYPE": {
-		Name:        "CHECK_FILE_TYPE",
-		Description: "Checks file type for a given path",
-		Usage:       "/execute?command=CHECK_FILE_TYPE&path=/path/to/file",
-	},
-	"CHECK_IS_FILE_TYPE": {
-		Name:        "CHECK_IS_FILE_TYPE",
-		Description: "Checks documents for a given path",
-		Usage:       "/execute?command=CHECK_IS_FILE_TYPE&path=/path/to/file",
-	},
-	"CHECK_FILE_DATES": {
-		Name:        "CHECK_FILE_DATES",
-		Description: "Checks file times",
-		Usage:       "/execute?command=CHECK_FILE_DATES&path=/path/to/file",
-	},
-	"CHECK_IF_MODIFIED_FILE": {
-		Name:        "CHECK_IF_MODIFIED_FILE",
-		Description: "Checks file modified for a given path",
-		Usage:       "/execute?command=CHECK_IF_MODIFIED_FILE&path=/path/to/file",
-	},
-}
-
 // healthCheckHandler - check system health
 func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	health := map[string]string{
+	health := map[string]interface{}{
 		"status":  "available",
 		"service": "running",
 	}
 
+	// undelivered api payloads
+	stats, err := app.outbox.Stats()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	health["outbox"] = map[string]interface{}{
+		"depth":              stats.Depth,
+		"oldest_age_seconds": int(stats.OldestAge.Seconds()),
+		"dead_letters":       stats.DeadLetters,
+	}
+
+	// outcome of the last config.yaml reload
+	if reload := app.reloadState(); reload != nil {
+		health["config_reload"] = reload
+	}
+
 	if err := app.writeJSON(w, http.StatusOK, health, nil); err != nil {
 		app.serverError(w, r, err)
 		return
@@ -71,6 +54,66 @@ func (app *application) logsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// eventsHandler - query the stored change events
+// filters: path (prefix), type (comma separated), since and until (RFC3339), after (event id) and limit
+func (app *application) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := eventstore.Filter{
+		PathPrefix: query.Get("path"),
+		Limit:      100,
+	}
+
+	if types := query.Get("type"); types != "" {
+		for _, eventType := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, snapshot.EventType(strings.TrimSpace(eventType)))
+		}
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			app.badRequest(w, r, fmt.Errorf("since must be an RFC3339 time"))
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			app.badRequest(w, r, fmt.Errorf("until must be an RFC3339 time"))
+			return
+		}
+	}
+	if after := query.Get("after"); after != "" {
+		if filter.After, err = strconv.ParseUint(after, 10, 64); err != nil {
+			app.badRequest(w, r, fmt.Errorf("after must be an event id"))
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 || filter.Limit > 1000 {
+			app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 1000"))
+			return
+		}
+	}
+
+	records, err := app.events.Events(filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the id to pass as after for the next page, none when this is the last page
+	response := map[string]interface{}{"events": records}
+	if len(records) == filter.Limit {
+		response["next"] = records[len(records)-1].ID
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, response, nil); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
+
 // helpCommandsHandler - handle help commands
 func (app *application) helpCommandsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -79,23 +122,26 @@ func (app *application) helpCommandsHandler(w http.ResponseWriter, r *http.Reque
 
 // ----------------- COMMANDS ----------------- //
 
-// commandQueryHandler handles queries about commands
+// commandQueryHandler handles queries about commands, generated from the command registry
 func (app *application) commandQueryHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	command := query.Get("command")
+	name := query.Get("command")
 
-	if command == "" {
-		if err := app.writeJSON(w, http.StatusOK, commandInfoMap, nil); err != nil {
+	if name == "" {
+		commands := make(map[string]command.Command)
+		for _, c := range app.service.CommandRunFile.Commands() {
+			commands[c.Name] = c
+		}
+
+		if err := app.writeJSON(w, http.StatusOK, commands, nil); err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 		return
 	}
 
-	// convert command to uppercase for case-insensitive matching
-	command = strings.ToUpper(command)
-
-	if info, ok := commandInfoMap[command]; ok {
+	// lookup is case-insensitive
+	if info, ok := app.service.CommandRunFile.Lookup(name); ok {
 		if err := app.writeJSON(w, http.StatusOK, info, nil); err != nil {
 			app.serverError(w, r, err)
 			return
@@ -106,18 +152,25 @@ func (app *application) commandQueryHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// commandExecuteHandler handles execution of commands
+// commandExecuteHandler handles execution of commands, the api key needs the role the command requires
 func (app *application) commandExecuteHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	command := query.Get("command")
+	name := query.Get("command")
 
-	if command == "" {
+	if name == "" {
 		http.Error(w, "Command parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	// convert command to uppercase for case-insensitive matching
-	command = strings.ToUpper(command)
+	info, ok := app.service.CommandRunFile.Lookup(name)
+	if !ok {
+		app.badRequest(w, r, fmt.Errorf("unknown command: %s", strings.ToUpper(name)))
+		return
+	}
+	if !app.hasRole(r, info.Role) {
+		app.forbidden(w, r)
+		return
+	}
 
 	params := make(map[string]string)
 	for key, values := range query {
@@ -126,9 +179,9 @@ func (app *application) commandExecuteHandler(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	result, err := app.service.CommandRunFile.ExecuteCommand(command, params)
+	result, err := app.service.CommandRunFile.ExecuteCommand(r.Context(), info.Name, params)
 	if err != nil {
-		app.badRequest(w, r, err)
+		app.commandError(w, r, err)
 		return
 	}
 
@@ -138,36 +191,61 @@ func (app *application) commandExecuteHandler(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// fileHistoryHandler - GET /files/{path}/history runs FILE_HISTORY on the path, e.g. /files/var/log/app.log/history
+func (app *application) fileHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/files/")
+	if !strings.HasSuffix(rest, "/history") || rest == "/history" {
+		app.notFound(w, r)
+		return
+	}
+	rest = strings.TrimSuffix(rest, "/history")
+
+	// the leading slash of the path is part of the route, a windows path starts with its volume instead
+	path := "/" + rest
+	if filepath.VolumeName(filepath.FromSlash(rest)) != "" {
+		path = rest
+	}
+
+	info, ok := app.service.CommandRunFile.Lookup("FILE_HISTORY")
+	if !ok {
+		app.notFound(w, r)
+		return
+	}
+	if !app.hasRole(r, info.Role) {
+		app.forbidden(w, r)
+		return
+	}
+
+	result, err := app.service.CommandRunFile.ExecuteCommand(r.Context(), info.Name, map[string]string{"path": filepath.FromSlash(path)})
+	if err != nil {
+		if errors.Is(err, command.ErrNoHistory) {
+			app.errorMessage(w, r, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		app.commandError(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, result, nil); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
 // ----------------- FOR UI SIDE ----------------- //
 // startServiceHandler - start work and thread service if not running
 func (app *application) startServiceHandler(w http.ResponseWriter, r *http.Request) {
 	//if not running start service
 	if !app.isRunning {
-		app.isRunning = true
-		app.serviceStopper = make(chan struct{})
-		app.wg.Add(2)
-
-		//start workerThread
-		go func() {
-			err := app.workerThread()
-			if err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-		}()
-
-		//start timeThread
-		go func() {
-			err := app.timerThread()
-			if err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-		}()
+		app.startService()
 
 		w.WriteHeader(http.StatusOK)
 	} else {
-		app.badRequest(w, r, fmt.Errorf("service is already stopped"))
+		app.badRequest(w, r, fmt.Errorf("service is already running"))
 	}
 }
 
@@ -175,9 +253,7 @@ func (app *application) startServiceHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) stopServiceHandler(w http.ResponseWriter, r *http.Request) {
 	//if running stop service
 	if app.isRunning {
-		app.isRunning = false
-		close(app.serviceStopper)
-		app.wg.Wait()
+		app.stopService()
 
 		w.WriteHeader(http.StatusOK)
 	} else {
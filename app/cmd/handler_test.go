@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+)
+
+// TestFileHistoryHandler - the path after /files/ is the file, its stored scans are returned oldest first
+func TestFileHistoryHandler(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	events, err := eventstore.Open(filepath.Join(t.TempDir(), "events.db"), eventstore.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open event store: %v", err)
+	}
+	defer events.Close()
+
+	for _, size := range []string{"1", "2"} {
+		if err := events.AddScan(filetrack.FileInfo{Path: file, FileSize: size, Permission: "0644", ModifiedTime: "1", AccessedTime: "1", ChangedTime: "1"}); err != nil {
+			t.Fatalf("Failed to add scan: %v", err)
+		}
+	}
+
+	cfg := config.Config{AllowedRoots: []config.AllowedRoot{{Path: dir}}}
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		config:   cfg,
+		events:   events,
+		service:  service.NewService(cfg, "", events),
+	}
+
+	routes := app.routes()
+	request := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(method, "/files/"+strings.TrimPrefix(filepath.ToSlash(path), "/")+"/history", nil))
+		return rr
+	}
+
+	rr := request(http.MethodGet, file)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var history command.FileHistory
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if history.Count != 2 || len(history.Versions[1].Changes) != 1 || history.Versions[1].Changes[0].Field != "size" {
+		t.Errorf("Expected two versions with the size change, got %+v", history)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"never scanned", http.MethodGet, filepath.Join(dir, "b.txt"), http.StatusNotFound},
+		{"outside roots", http.MethodGet, "/etc/passwd", http.StatusForbidden},
+		{"post", http.MethodPost, file, http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		if rr := request(test.method, test.path); rr.Code != test.expected {
+			t.Errorf("%s: got status %d; want %d (%s)", test.name, rr.Code, test.expected, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/history", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a route without a path to be 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
+	"github.com/thespider911/filetrackermodification/app/internal/service/jobs"
+	"net/http"
+	"strings"
+)
+
+// maxJobRequestBytes - largest POST /jobs body accepted
+const maxJobRequestBytes = 1 << 20
+
+// jobRequest - body of POST /jobs
+type jobRequest struct {
+	Command string            `json:"command"`
+	Params  map[string]string `json:"params"`
+}
+
+// runJob - run the command of a background job, the command reports its progress to the job
+func (app *application) runJob(ctx context.Context, name string, params map[string]string, progress func(done, total int)) (interface{}, error) {
+	return app.service.CommandRunFile.ExecuteCommand(command.WithProgress(ctx, progress), name, params)
+}
+
+// jobsHandler - POST queues a command as a background job, GET lists the kept jobs of the api key
+func (app *application) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		app.createJobHandler(w, r)
+	case http.MethodGet:
+		list := make([]jobs.Job, 0)
+		for _, job := range app.jobs.List() {
+			if app.canSeeJob(r, job) {
+				list = append(list, job)
+			}
+		}
+
+		if err := app.writeJSON(w, http.StatusOK, list, nil); err != nil {
+			app.serverError(w, r, err)
+		}
+	default:
+		app.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// createJobHandler - validate the command and its parameters like /execute, then queue it
+func (app *application) createJobHandler(w http.ResponseWriter, r *http.Request) {
+	var req jobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		app.badRequest(w, r, fmt.Errorf("invalid job request: %w", err))
+		return
+	}
+	if req.Command == "" {
+		app.badRequest(w, r, errors.New("command is required"))
+		return
+	}
+
+	info, ok := app.service.CommandRunFile.Lookup(req.Command)
+	if !ok {
+		app.badRequest(w, r, fmt.Errorf("unknown command: %s", strings.ToUpper(req.Command)))
+		return
+	}
+	if !app.hasRole(r, info.Role) {
+		app.forbidden(w, r)
+		return
+	}
+
+	// the parameters and paths are checked again when the job runs
+	if _, _, err := app.service.CommandRunFile.Prepare(info.Name, req.Params); err != nil {
+		app.commandError(w, r, err)
+		return
+	}
+
+	job, err := app.jobs.Submit(app.keyName(r), info.Name, req.Params)
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			app.errorMessage(w, r, http.StatusServiceUnavailable, "the job queue is full, try again later", nil)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusAccepted, job, http.Header{"Location": {"/jobs/" + job.ID}}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// jobHandler - GET /jobs/{id} returns the status, progress and result of a job, DELETE /jobs/{id} cancels it
+func (app *application) jobHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if id == "" || strings.Contains(id, "/") {
+		app.notFound(w, r)
+		return
+	}
+
+	// the jobs of other api keys are not found, not forbidden, so their ids cannot be probed
+	job, err := app.jobs.Get(id)
+	if err != nil || !app.canSeeJob(r, job) {
+		app.notFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		job, err = app.jobs.Cancel(id)
+		switch {
+		case errors.Is(err, jobs.ErrFinished):
+			app.errorMessage(w, r, http.StatusConflict, fmt.Sprintf("job %s has already %s", id, job.Status), nil)
+			return
+		case errors.Is(err, jobs.ErrNotFound):
+			app.notFound(w, r)
+			return
+		}
+	default:
+		app.methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, job, nil); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// canSeeJob - whether the api key of the request created the job and still has the role of its command
+func (app *application) canSeeJob(r *http.Request, job jobs.Job) bool {
+	role := roleOperator
+	if info, ok := app.service.CommandRunFile.Lookup(job.Command); ok {
+		role = info.Role
+	}
+
+	return job.Owner == app.keyName(r) && app.hasRole(r, role)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service"
+	"github.com/thespider911/filetrackermodification/app/internal/service/jobs"
+)
+
+// TestJobHandlers - a job is validated when queued, then its outcome is kept until the retention period ends
+func TestJobHandlers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := config.Config{AllowedRoots: []config.AllowedRoot{{Path: dir}}, OsqueryRowLimit: 10}
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		config:   cfg,
+		service:  service.NewService(cfg, "", nil),
+	}
+	app.jobs = jobs.NewManager(app.runJob, jobs.Options{Workers: 1, QueueSize: 10, Retention: time.Hour})
+	defer app.jobs.Close()
+
+	routes := app.routes()
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"outside roots", `{"command": "CHECK_FILE_DATES", "params": {"path": "/etc/passwd"}}`, http.StatusForbidden},
+		{"unknown command", `{"command": "NOPE"}`, http.StatusBadRequest},
+		{"missing parameter", `{"command": "check_file_dates"}`, http.StatusBadRequest},
+		{"unknown field", `{"command": "CHECK_FILE_DATES", "path": "/tmp"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if rr := request(http.MethodPost, "/jobs", test.body); rr.Code != test.expected {
+			t.Errorf("%s: got status %d; want %d (%s)", test.name, rr.Code, test.expected, rr.Body.String())
+		}
+	}
+
+	rr := request(http.MethodPost, "/jobs", `{"command": "check_file_dates", "params": {"path": "`+filepath.ToSlash(file)+`"}}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var job jobs.Job
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+		t.Fatalf("Failed to decode job: %v", err)
+	}
+	if rr.Header().Get("Location") != "/jobs/"+job.ID || job.Command != "CHECK_FILE_DATES" {
+		t.Errorf("Expected the job location and command name, got %s %s", rr.Header().Get("Location"), job.Command)
+	}
+
+	// the job finishes whether or not osquery is installed here
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Finished == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rr = request(http.MethodGet, "/jobs/"+job.ID, "")
+		if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+	}
+	if job.Status != jobs.StatusSucceeded && job.Status != jobs.StatusFailed {
+		t.Fatalf("Expected the job to finish, got %s", job.Status)
+	}
+
+	if rr := request(http.MethodDelete, "/jobs/"+job.ID, ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected a finished job not to be cancelled, got %d", rr.Code)
+	}
+	if rr := request(http.MethodGet, "/jobs/missing", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown job to be 404, got %d", rr.Code)
+	}
+	if rr := request(http.MethodPut, "/jobs", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected PUT to be 405, got %d", rr.Code)
+	}
+
+	var list []jobs.Job
+	if err := json.NewDecoder(request(http.MethodGet, "/jobs", "").Body).Decode(&list); err != nil || len(list) != 1 {
+		t.Errorf("Expected one job in the list, got %d (%v)", len(list), err)
+	}
+}
+
+// TestJobOwner - a job is only visible to the api key that created it
+func TestJobOwner(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cfg := config.Config{
+		AllowedRoots: []config.AllowedRoot{{Path: dir}},
+		APIKeys: []config.APIKey{
+			{Name: "alice", Key: "alice-key-0123456789", Role: roleOperator},
+			{Name: "bob", Key: "bob-key-0123456789", Role: roleOperator},
+		},
+	}
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		config:   cfg,
+		service:  service.NewService(cfg, "", nil),
+	}
+	app.jobs = jobs.NewManager(app.runJob, jobs.Options{Workers: 1, QueueSize: 10, Retention: time.Hour})
+	defer app.jobs.Close()
+
+	routes := app.routes()
+	request := func(key, method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("alice-key-0123456789", http.MethodPost, "/jobs", `{"command": "check_file_dates", "params": {"path": "`+filepath.ToSlash(file)+`"}}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var job jobs.Job
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil || job.Owner != "alice" {
+		t.Fatalf("Expected the job to be owned by alice, got %q (%v)", job.Owner, err)
+	}
+
+	if rr := request("alice-key-0123456789", http.MethodGet, "/jobs/"+job.ID, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected the owner to see the job, got %d", rr.Code)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if rr := request("bob-key-0123456789", method, "/jobs/"+job.ID, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected another key to get 404 on %s, got %d", method, rr.Code)
+		}
+	}
+
+	var list []jobs.Job
+	if err := json.NewDecoder(request("bob-key-0123456789", http.MethodGet, "/jobs", "").Body).Decode(&list); err != nil || len(list) != 0 {
+		t.Errorf("Expected another key to list no jobs, got %d (%v)", len(list), err)
+	}
+}
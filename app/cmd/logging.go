@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/thespider911/filetrackermodification/app/internal/helpers"
 	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
 	"log"
 	"os"
 )
@@ -20,16 +21,17 @@ func (app *application) logging() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 }
 
-// logFileInfo - log to file and in-memory
-func (app *application) logFileInfo(fileInfo filetrack.FileInfo) error {
+// logEvent - log change event to file and in-memory
+func (app *application) logEvent(event snapshot.Event) error {
 	// update file result to readable info
-	fileInfo.ModifiedTime = helpers.ToHumanReadableTime(fileInfo.ModifiedTime)
-	fileInfo.AccessedTime = helpers.ToHumanReadableTime(fileInfo.AccessedTime)
-	fileInfo.ChangedTime = helpers.ToHumanReadableTime(fileInfo.ChangedTime)
-	fileInfo.FileSize = helpers.ToHumanReadableFileSize(fileInfo.FileSize)
+	event.FileInfo = humanReadableFileInfo(event.FileInfo)
+	if event.Previous != nil {
+		previous := humanReadableFileInfo(*event.Previous)
+		event.Previous = &previous
+	}
 
-	//convert file info to json
-	js, err := app.JSON(fileInfo)
+	//convert event to json
+	js, err := app.JSON(event)
 	if err != nil {
 		return err
 	}
@@ -44,7 +46,17 @@ func (app *application) logFileInfo(fileInfo filetrack.FileInfo) error {
 	if len(app.logBuffer) >= 1000 {
 		app.logBuffer = app.logBuffer[1:]
 	}
-	app.logBuffer = append(app.logBuffer, fileInfo)
+	app.logBuffer = append(app.logBuffer, event)
 
 	return nil
 }
+
+// humanReadableFileInfo - format the times and size of a file info for the logs
+func humanReadableFileInfo(fileInfo filetrack.FileInfo) filetrack.FileInfo {
+	fileInfo.ModifiedTime = helpers.ToHumanReadableTime(fileInfo.ModifiedTime)
+	fileInfo.AccessedTime = helpers.ToHumanReadableTime(fileInfo.AccessedTime)
+	fileInfo.ChangedTime = helpers.ToHumanReadableTime(fileInfo.ChangedTime)
+	fileInfo.FileSize = helpers.ToHumanReadableFileSize(fileInfo.FileSize)
+
+	return fileInfo
+}
@@ -1,17 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/theme"
-	"fyne.io/fyne/v2/widget"
 	"github.com/thespider911/filetrackermodification/app/internal/config"
 	"github.com/thespider911/filetrackermodification/app/internal/service"
-	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/integrity"
+	"github.com/thespider911/filetrackermodification/app/internal/service/jobs"
+	"github.com/thespider911/filetrackermodification/app/internal/service/outbox"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
 	"github.com/thespider911/filetrackermodification/app/internal/testutil"
-	"image/color"
 	"log"
 	"net/http"
 	"os"
@@ -19,34 +19,6 @@ import (
 	"time"
 )
 
-// customTheme - theme with black background and white text
-type customTheme struct{}
-
-var _ fyne.Theme = (*customTheme)(nil)
-
-func (m customTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
-	return theme.DefaultTheme().Icon(name)
-}
-
-func (m customTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
-	if name == theme.ColorNameBackground {
-		if variant == theme.VariantLight {
-			return color.White
-		}
-		return color.Black
-	}
-
-	return theme.DefaultTheme().Color(name, variant)
-}
-
-func (m customTheme) Font(style fyne.TextStyle) fyne.Resource {
-	return theme.DefaultTheme().Font(style)
-}
-
-func (m customTheme) Size(name fyne.ThemeSizeName) float32 {
-	return theme.DefaultTheme().Size(name)
-}
-
 type Command struct {
 	Type string
 	Data interface{}
@@ -56,21 +28,35 @@ type application struct {
 	infoLog        *log.Logger
 	errorLog       *log.Logger
 	config         config.Config
+	configMu       sync.RWMutex
+	reloaded       chan struct{}
+	reload         *reloadStatus
 	wg             sync.WaitGroup
 	wgCount        int32
 	logFile        *os.File
 	service        service.Service
 	commandQueue   chan Command
 	logBufferMu    sync.RWMutex
-	logBuffer      []filetrack.FileInfo
+	logBuffer      []snapshot.Event
+	snapshots      *snapshot.Store
+	baseline       *integrity.Baseline
+	events         *eventstore.Store
+	outbox         *outbox.Outbox
+	stream         *eventBroker
+	jobs           *jobs.Manager
+	metrics        *appMetrics
 	httpClient     *http.Client
 	isRunning      bool
 	serviceStopper chan struct{}
-	uiLogs         *widget.Entry
 	logChan        chan string
 }
 
 func main() {
+	headless := flag.Bool("headless", !guiAvailable, "run the service and http api without the GUI")
+	pidFile := flag.String("pid-file", "filetracker.pid", "file the process id is written to in headless mode")
+	socket := flag.String("socket", "", "osquery extension socket, queries run with osqueryi when it is not set or cannot be reached")
+	flag.Parse()
+
 	//setup data to test
 	if err := testutil.SetupTestEnvironment(); err != nil {
 		fmt.Printf("Error setting up test environment: %v\n", err)
@@ -85,73 +71,100 @@ func main() {
 	if err != nil {
 		errorLog.Fatal(err)
 	}
+	if err := command.Default().CheckRoots(cfg.AllowedRoots); err != nil {
+		errorLog.Fatal(err)
+	}
 
 	application := &application{
 		infoLog:        infoLog,
 		errorLog:       errorLog,
 		config:         *cfg,
-		service:        service.NewService(),
 		commandQueue:   make(chan Command, cfg.QueueSize),
-		logBuffer:      make([]filetrack.FileInfo, 0, 1000),
+		logBuffer:      make([]snapshot.Event, 0, 1000),
+		snapshots:      snapshot.NewStore(),
+		reloaded:       make(chan struct{}),
+		stream:         newEventBroker(),
 		httpClient:     &http.Client{Timeout: 10 * time.Second},
 		isRunning:      false,
 		serviceStopper: make(chan struct{}),
 		logChan:        make(chan string, 100),
 	}
 
-	//set up logging
-	application.logging()
-	defer application.logFile.Close()
+	// baseline create|verify run instead of the service
+	if args := flag.Args(); len(args) > 0 && args[0] == "baseline" {
+		os.Exit(application.runBaseline(args[1:]))
+	}
 
-	err = application.checkDirectory()
+	// load the approved baseline, this switches on hashing when there is one
+	if err := application.loadBaseline(); err != nil {
+		errorLog.Fatal(err)
+	}
+	// open the event history and restore the last snapshot from it
+	application.events, err = eventstore.Open(cfg.EventStore, eventstore.Options{
+		MaxScans:  cfg.EventStoreMaxScans,
+		MaxEvents: cfg.EventStoreMaxEvents,
+	})
 	if err != nil {
-		errorLog.Printf("Directory check failed: %v\n", err)
-		return
+		errorLog.Fatal(err)
 	}
+	defer application.events.Close()
 
-	// Create Fyne application
-	myApp := app.New()
-	myApp.Settings().SetTheme(&customTheme{}) // custom theme
-	myWindow := myApp.NewWindow("File Tracker Service")
+	latest, err := application.events.Latest()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	application.snapshots.Seed(latest)
 
-	// Create UI elements
-	application.uiLogs = widget.NewMultiLineEntry()
-	application.uiLogs.Disable()
+	// FILE_HISTORY reads the recorded scans from the event history
+	application.service = service.NewService(application.config, *socket, application.events)
+	defer application.service.Osquery.Close()
 
-	startButton := widget.NewButton("Start Service", func() {
-		if !application.isRunning {
-			application.startService()
-		}
+	// background jobs of /jobs, run off the request on their own workers
+	application.jobs = jobs.NewManager(application.runJob, jobs.Options{
+		Workers:   cfg.JobWorkers,
+		QueueSize: cfg.JobQueueSize,
+		Retention: time.Duration(cfg.JobRetention) * time.Second,
 	})
+	defer application.jobs.Close()
 
-	stopButton := widget.NewButton("Stop Service", func() {
-		if application.isRunning {
-			application.stopService()
-		}
+	// metrics, FetchFilesInfo is timed by wrapping the tracker
+	application.metrics = newAppMetrics(application)
+	application.service.FileTracker = instrumentedFileTracker{
+		FileTracker: application.service.FileTracker,
+		metrics:     application.metrics,
+	}
+
+	// open the outbox of undelivered api payloads
+	application.outbox, err = outbox.Open(cfg.OutboxFile, outbox.Options{
+		MaxAttempts: cfg.OutboxMaxAttempts,
+		BaseDelay:   time.Duration(cfg.OutboxRetryBase) * time.Second,
+		MaxDelay:    time.Duration(cfg.OutboxRetryMax) * time.Second,
 	})
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	defer application.outbox.Close()
 
-	buttons := container.NewHBox(startButton, stopButton)
-	content := container.NewBorder(buttons, nil, nil, nil, application.uiLogs)
+	// pick up edits of config.yaml without a restart
+	config.WatchConfig(application.reloadConfig)
 
-	// Set up window
-	myWindow.SetContent(content)
-	myWindow.Resize(fyne.NewSize(600, 400))
+	//set up logging
+	application.logging()
+	defer application.logFile.Close()
 
-	// Start log update goroutine
-	go application.updateLogs()
+	application.checkDirectory(cfg.Watches...)
 
-	// start HTTP server
-	application.wg.Add(1)
-	go func() {
-		defer application.wg.Done()
-		if err := application.serveHttp(); err != nil {
-			application.errorLog.Println(err)
-			os.Exit(0)
+	// without a window the http api is the only client
+	if *headless {
+		if err := application.runHeadless(*pidFile); err != nil {
+			errorLog.Println(err)
+			os.Exit(1)
 		}
-	}()
-
-	// Run the UI
-	myWindow.ShowAndRun()
+		return
+	}
 
-	application.wg.Wait()
+	if err := application.runGUI(); err != nil {
+		errorLog.Println(err)
+		os.Exit(1)
+	}
 }
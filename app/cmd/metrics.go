@@ -0,0 +1,187 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+	"net/http"
+	"time"
+)
+
+// latencyBuckets - histogram buckets in seconds
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// appMetrics - tracker internals exposed on /metrics, the methods record nothing on a nil appMetrics
+type appMetrics struct {
+	registry        *prometheus.Registry
+	scans           prometheus.Counter
+	scanDuration    prometheus.Histogram
+	scanFiles       *prometheus.GaugeVec
+	queueDropped    prometheus.Counter
+	fetchDuration   prometheus.Histogram
+	fetchErrors     prometheus.Counter
+	events          *prometheus.CounterVec
+	apiDeliveries   *prometheus.CounterVec
+	apiPostDuration prometheus.Histogram
+}
+
+// newAppMetrics - register the tracker metrics, the gauges read the queue and outbox of app
+func newAppMetrics(app *application) *appMetrics {
+	m := &appMetrics{
+		registry: prometheus.NewRegistry(),
+		scans: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "filetracker_scans_total", Help: "Directory scans started.",
+		}),
+		scanDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "filetracker_scan_duration_seconds", Help: "Time to walk the directory and queue its files.", Buckets: latencyBuckets,
+		}),
+		scanFiles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "filetracker_scan_files", Help: "Files found by the last scan of the watch target.",
+		}, []string{"target"}),
+		queueDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "filetracker_command_queue_dropped_total", Help: "Commands skipped because the command queue was full.",
+		}),
+		fetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "filetracker_fetch_duration_seconds",
+			Help:    "Time to fetch the info of a file, including the osquery query with the osquery backend.",
+			Buckets: latencyBuckets,
+		}),
+		fetchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "filetracker_fetch_errors_total", Help: "Failed file info fetches, including osquery query failures.",
+		}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "filetracker_events_total", Help: "Change events published.",
+		}, []string{"type"}),
+		apiDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "filetracker_api_deliveries_total", Help: "Requests posted to the api endpoint.",
+		}, []string{"result"}),
+		apiPostDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "filetracker_api_request_duration_seconds", Help: "Time to post a request to the api endpoint.", Buckets: latencyBuckets,
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.scans, m.scanDuration, m.scanFiles, m.queueDropped, m.fetchDuration, m.fetchErrors,
+		m.events, m.apiDeliveries, m.apiPostDuration,
+	)
+
+	// every event type and delivery result is exposed from the start, at 0
+	for _, eventType := range []snapshot.EventType{
+		snapshot.Created, snapshot.ContentModified, snapshot.MetadataChanged, snapshot.PermissionChanged,
+		snapshot.Deleted, snapshot.Renamed, snapshot.IntegrityViolation,
+	} {
+		m.events.WithLabelValues(string(eventType))
+	}
+	m.apiDeliveries.WithLabelValues("success")
+	m.apiDeliveries.WithLabelValues("failure")
+
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "filetracker_command_queue_depth", Help: "Commands waiting in the command queue.",
+		}, func() float64 {
+			return float64(len(app.commandQueue))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "filetracker_command_queue_capacity", Help: "Size of the command queue.",
+		}, func() float64 {
+			return float64(cap(app.commandQueue))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "filetracker_outbox_depth", Help: "Records waiting to be delivered to the api endpoint.",
+		}, func() float64 {
+			stats, err := app.outbox.Stats()
+			if err != nil {
+				return 0
+			}
+			return float64(stats.Depth)
+		}),
+	)
+
+	return m
+}
+
+// scanned - record a scan of a target that took since start, files is the number it found
+func (m *appMetrics) scanned(target string, start time.Time, files int) {
+	if m == nil {
+		return
+	}
+
+	m.scans.Inc()
+	m.scanDuration.Observe(time.Since(start).Seconds())
+	m.scanFiles.WithLabelValues(target).Set(float64(files))
+}
+
+// scanFailed - record a scan of a target that could not be walked
+func (m *appMetrics) scanFailed(start time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.scans.Inc()
+	m.scanDuration.Observe(time.Since(start).Seconds())
+}
+
+// targetRemoved - stop exporting the file count of a watch target removed by a reload
+func (m *appMetrics) targetRemoved(target string) {
+	if m == nil {
+		return
+	}
+
+	m.scanFiles.DeleteLabelValues(target)
+}
+
+// commandDropped - record a command skipped because the queue was full
+func (m *appMetrics) commandDropped() {
+	if m == nil {
+		return
+	}
+
+	m.queueDropped.Inc()
+}
+
+// published - record a published change event
+func (m *appMetrics) published(eventType snapshot.EventType) {
+	if m == nil {
+		return
+	}
+
+	m.events.WithLabelValues(string(eventType)).Inc()
+}
+
+// delivered - record a request posted to the api endpoint since start, err is why it failed
+func (m *appMetrics) delivered(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.apiPostDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.apiDeliveries.WithLabelValues("failure").Inc()
+		return
+	}
+	m.apiDeliveries.WithLabelValues("success").Inc()
+}
+
+// instrumentedFileTracker - records the latency and failures of FetchFilesInfo
+type instrumentedFileTracker struct {
+	filetrack.FileTracker
+	metrics *appMetrics
+}
+
+// FetchFilesInfo - time the wrapped tracker
+func (ft instrumentedFileTracker) FetchFilesInfo(filePath string) (*filetrack.FileInfo, error) {
+	start := time.Now()
+	fileInfo, err := ft.FileTracker.FetchFilesInfo(filePath)
+	ft.metrics.fetchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		ft.metrics.fetchErrors.Inc()
+	}
+
+	return fileInfo, err
+}
+
+// metricsHandler - metrics in the prometheus text format
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{ErrorLog: app.errorLog}).ServeHTTP(w, r)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"net/http"
+	"strings"
+)
+
+// roles of the control api keys, an operator can also call every read-only endpoint
+const (
+	roleRead     = "read"
+	roleOperator = "operator"
+)
+
+// contextKey - keys of the request context values set by the middleware
+type contextKey string
+
+// roleContextKey and keyNameContextKey - role and name of the api key a request was sent with
+const (
+	roleContextKey    = contextKey("role")
+	keyNameContextKey = contextKey("key")
+)
+
+// requireRole - only let requests through with an api key that has the given role.
+// Keys are sent as "Authorization: Bearer <key>" or "X-API-Key: <key>",
+// when no api_keys are configured every request is allowed.
+func (app *application) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(app.currentConfig().APIKeys) == 0 {
+			next(w, r)
+			return
+		}
+
+		// a bare key in Authorization is not accepted, it has to be a bearer token
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); key == "" && ok {
+			key = bearer
+		}
+
+		apiKey, ok := app.apiKey(key)
+		if !ok {
+			app.unauthorized(w, r)
+			return
+		}
+
+		if role == roleOperator && apiKey.Role != roleOperator {
+			app.forbidden(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), roleContextKey, apiKey.Role)
+		next(w, r.WithContext(context.WithValue(ctx, keyNameContextKey, apiKey.Name)))
+	}
+}
+
+// hasRole - whether the api key of the request has the role, always true when no api_keys are configured
+func (app *application) hasRole(r *http.Request, role string) bool {
+	if len(app.currentConfig().APIKeys) == 0 {
+		return true
+	}
+
+	keyRole, _ := r.Context().Value(roleContextKey).(string)
+	return keyRole == roleOperator || (keyRole == roleRead && role == roleRead)
+}
+
+// keyName - name of the api key of the request, empty when no api_keys are configured
+func (app *application) keyName(r *http.Request) string {
+	name, _ := r.Context().Value(keyNameContextKey).(string)
+	return name
+}
+
+// apiKey - the configured api key, comparing every key in constant time
+func (app *application) apiKey(key string) (config.APIKey, bool) {
+	if key == "" {
+		return config.APIKey{}, false
+	}
+
+	var found config.APIKey
+	ok := false
+	for _, apiKey := range app.currentConfig().APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey.Key)) == 1 {
+			found, ok = apiKey, true
+		}
+	}
+
+	return found, ok
+}
+
+// requireClientCert - with a client CA configured, only clients with a verified certificate get through.
+// tls_client_ca_file is only read at startup, so it is read once when the route is set up.
+func (app *application) requireClientCert(next http.HandlerFunc) http.HandlerFunc {
+	required := app.currentConfig().TLSClientCAFile != ""
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if required && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			app.forbidden(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/command"
+	"reflect"
+	"time"
+)
+
+// startupKeys - config keys only read at startup, a reload keeps their running value until the next restart
+var startupKeys = map[string]bool{
+	"http_host":              true,
+	"http_port":              true,
+	"queue_size":             true,
+	"tracker_backend":        true,
+	"watch_mode":             true,
+	"hash_algorithm":         true,
+	"baseline_file":          true,
+	"event_store":            true,
+	"event_store_max_scans":  true,
+	"event_store_max_events": true,
+	"outbox_file":            true,
+	"outbox_max_attempts":    true,
+	"outbox_retry_base":      true,
+	"outbox_retry_max":       true,
+	"tls_enabled":            true,
+	"tls_cert_file":          true,
+	"tls_key_file":           true,
+	"tls_client_ca_file":     true,
+	"allowed_roots":          true,
+	"osquery_tables":         true,
+	"osquery_row_limit":      true,
+	"osquery_timeout":        true,
+	"job_workers":            true,
+	"job_queue_size":         true,
+	"job_retention":          true,
+}
+
+// reloadStatus - outcome of the last config reload, reported on /health
+type reloadStatus struct {
+	Time            time.Time `json:"time"`
+	Status          string    `json:"status"`
+	Error           string    `json:"error,omitempty"`
+	RestartRequired []string  `json:"restart_required,omitempty"`
+}
+
+// currentConfig - copy of the running config, safe to use while a reload swaps it
+func (app *application) currentConfig() config.Config {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	return app.config
+}
+
+// configReloaded - channel closed when the running config is swapped
+func (app *application) configReloaded() <-chan struct{} {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	return app.reloaded
+}
+
+/*
+	reloadConfig
+
+- called by config.WatchConfig whenever config.yaml changes
+- an edit that does not load or validate is rejected and the last good config keeps running
+- keys only read at startup keep their running value and are reported as needing a restart
+- the timer and watcher threads pick up the new watch targets and intervals when the config is swapped
+*/
+func (app *application) reloadConfig(previous, loaded config.Config, err error) {
+	if err == nil {
+		// the api keeps listening on the running http_host until a restart
+		listening := loaded
+		listening.HttpHost = app.currentConfig().HttpHost
+		err = config.CheckAPIAuth(listening)
+	}
+	if err == nil {
+		err = command.Default().CheckRoots(loaded.AllowedRoots)
+	}
+
+	status := reloadStatus{Time: time.Now(), Status: "ok"}
+	if err != nil {
+		status.Status = "rejected"
+		status.Error = err.Error()
+		app.errorLog.Printf("Config reload rejected, keeping the running config: %v\n", err)
+		app.appendLog("Config reload rejected, keeping the running config.\n")
+
+		app.configMu.Lock()
+		app.reload = &status
+		app.configMu.Unlock()
+		return
+	}
+
+	app.configMu.Lock()
+	status.RestartRequired = keepStartupSettings(app.config, previous, &loaded)
+	removed := removedTargets(app.config.Watches, loaded.Watches)
+	app.config = loaded
+	app.reload = &status
+	if app.reloaded != nil {
+		close(app.reloaded)
+	}
+	app.reloaded = make(chan struct{})
+	app.configMu.Unlock()
+
+	for _, path := range removed {
+		app.metrics.targetRemoved(path)
+	}
+
+	app.infoLog.Println("Config reloaded")
+	app.appendLog("Config reloaded.\n")
+	if len(status.RestartRequired) > 0 {
+		app.infoLog.Printf("Restart to apply the changed keys: %v\n", status.RestartRequired)
+	}
+}
+
+// reloadState - outcome of the last reload, nil when the config was not reloaded yet
+func (app *application) reloadState() *reloadStatus {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	return app.reload
+}
+
+// removedTargets - paths of the running watch targets the loaded config no longer has
+func removedTargets(running, loaded []config.WatchTarget) []string {
+	kept := make(map[string]bool, len(loaded))
+	for _, target := range loaded {
+		kept[target.Path] = true
+	}
+
+	var removed []string
+	for _, target := range running {
+		if !kept[target.Path] {
+			removed = append(removed, target.Path)
+		}
+	}
+
+	return removed
+}
+
+// keepStartupSettings - set the startup keys of loaded to their running value,
+// returns the keys whose value changed in the file since the previous load
+func keepStartupSettings(running, previous config.Config, loaded *config.Config) []string {
+	var changed []string
+
+	runningValue := reflect.ValueOf(running)
+	previousValue := reflect.ValueOf(previous)
+	loadedValue := reflect.ValueOf(loaded).Elem()
+	for i := 0; i < loadedValue.NumField(); i++ {
+		key := loadedValue.Type().Field(i).Tag.Get("mapstructure")
+		if !startupKeys[key] {
+			continue
+		}
+
+		if !reflect.DeepEqual(loadedValue.Field(i).Interface(), previousValue.Field(i).Interface()) {
+			changed = append(changed, key)
+		}
+		loadedValue.Field(i).Set(runningValue.Field(i))
+	}
+
+	return changed
+}
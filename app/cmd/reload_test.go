@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// TestReloadConfig - a valid reload swaps the config but keeps the startup keys, an invalid one is rejected
+func TestReloadConfig(t *testing.T) {
+	running := config.Config{HttpHost: "127.0.0.1", HttpPort: 4000, CheckInterval: 60, Watches: []config.WatchTarget{{Path: "/a"}}}
+	app := &application{
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+		config:   running,
+		reloaded: make(chan struct{}),
+		logChan:  make(chan string, 10),
+	}
+	reloaded := app.configReloaded()
+	app.metrics = newAppMetrics(app)
+	app.metrics.scanned("/a", time.Now(), 3)
+
+	loaded := config.Config{HttpHost: "127.0.0.1", HttpPort: 4001, CheckInterval: 30, Watches: []config.WatchTarget{{Path: "/b"}}}
+	app.reloadConfig(running, loaded, nil)
+
+	select {
+	case <-reloaded:
+	default:
+		t.Fatal("Expected the reload to be signalled")
+	}
+
+	current := app.currentConfig()
+	if current.CheckInterval != 30 || current.Watches[0].Path != "/b" {
+		t.Errorf("Expected the reloaded interval and targets, got %d and %v", current.CheckInterval, current.Watches)
+	}
+	// the file count of the removed target is no longer exported
+	series := make(chan prometheus.Metric, 10)
+	app.metrics.scanFiles.Collect(series)
+	if len(series) != 0 {
+		t.Errorf("Expected the file count of /a to be removed, got %d series", len(series))
+	}
+	if current.HttpPort != 4000 {
+		t.Errorf("Expected http_port to keep its running value, got %d", current.HttpPort)
+	}
+
+	status := app.reloadState()
+	if status == nil || status.Status != "ok" || len(status.RestartRequired) != 1 || status.RestartRequired[0] != "http_port" {
+		t.Fatalf("Expected an ok reload needing a restart for http_port, got %+v", status)
+	}
+
+	// a rejected edit keeps the last good config
+	app.reloadConfig(loaded, config.Config{}, errors.New("Key: 'Config.Watches' Error:Field validation for 'Watches' failed on the 'required' tag"))
+	if app.currentConfig().CheckInterval != 30 {
+		t.Errorf("Expected the last good config to keep running, got %+v", app.currentConfig())
+	}
+	if status := app.reloadState(); status.Status != "rejected" || status.Error == "" {
+		t.Errorf("Expected a rejected reload with its error, got %+v", status)
+	}
+
+	// the api keeps listening on all interfaces until a restart, so it cannot lose its keys
+	app.config = config.Config{HttpPort: 4000, CheckInterval: 30, APIKeys: []config.APIKey{{Name: "admin", Key: "an-operator-key-1234", Role: roleOperator}}}
+	app.reloadConfig(app.config, config.Config{HttpHost: "127.0.0.1", HttpPort: 4000, CheckInterval: 30}, nil)
+	if len(app.currentConfig().APIKeys) != 1 {
+		t.Errorf("Expected the api keys to stay while the api listens on all interfaces, got %+v", app.currentConfig())
+	}
+	if status := app.reloadState(); status.Status != "rejected" {
+		t.Errorf("Expected the reload removing the api keys to be rejected, got %+v", status)
+	}
+
+	// a misspelt command under an allowed root is rejected instead of denying the command
+	misspelt := app.currentConfig()
+	misspelt.AllowedRoots = []config.AllowedRoot{{Path: "/var/log", Commands: []string{"CHECK_FILE_MODIFIED"}}}
+	app.reloadConfig(app.config, misspelt, nil)
+	if status := app.reloadState(); status.Status != "rejected" || !strings.Contains(status.Error, "CHECK_FILE_MODIFIED") {
+		t.Errorf("Expected the reload with an unknown command to be rejected, got %+v", status)
+	}
+}
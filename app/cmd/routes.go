@@ -2,18 +2,26 @@ package main
 
 import "net/http"
 
-// routes - http requests
+// routes - http requests, read-only endpoints need a read or operator api key, the rest an operator key,
+// /execute, /execute/batch, /jobs and /files need the role of the command and also a client certificate when mTLS is configured
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/health", app.healthCheckHandler) //check system health
-	mux.HandleFunc("/logs", app.logsHandler)          //log result
+	mux.HandleFunc("/health", app.requireRole(roleRead, app.healthCheckHandler)) //check system health
+	mux.HandleFunc("/logs", app.requireRole(roleRead, app.logsHandler))          //log result
+	mux.HandleFunc("/events", app.requireRole(roleRead, app.eventsHandler))      //query event history
+	mux.HandleFunc("/stream", app.requireRole(roleRead, app.streamHandler))      //live event stream
+	mux.HandleFunc("/metrics", app.requireRole(roleRead, app.metricsHandler))    //prometheus metrics
 
-	mux.HandleFunc("/help", app.commandQueryHandler)      //display commands
-	mux.HandleFunc("/execute", app.commandExecuteHandler) // execute commands
+	mux.HandleFunc("/help", app.requireRole(roleRead, app.commandQueryHandler))                                 //display commands
+	mux.HandleFunc("/execute", app.requireRole(roleRead, app.requireClientCert(app.commandExecuteHandler)))     // execute commands
+	mux.HandleFunc("/execute/batch", app.requireRole(roleRead, app.requireClientCert(app.executeBatchHandler))) // execute a list of commands
+	mux.HandleFunc("/jobs", app.requireRole(roleRead, app.requireClientCert(app.jobsHandler)))                  // queue and list background jobs
+	mux.HandleFunc("/files/", app.requireRole(roleRead, app.requireClientCert(app.fileHistoryHandler)))         // recorded history of a file
+	mux.HandleFunc("/jobs/", app.requireRole(roleRead, app.requireClientCert(app.jobHandler)))                  // job status and cancel
 
-	mux.HandleFunc("/start", app.startServiceHandler) //start service
-	mux.HandleFunc("/stop", app.stopServiceHandler)   //stop service
+	mux.HandleFunc("/start", app.requireRole(roleOperator, app.startServiceHandler)) //start service
+	mux.HandleFunc("/stop", app.requireRole(roleOperator, app.stopServiceHandler))   //stop service
 
 	return mux
 }
@@ -2,29 +2,132 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-// sentToApi - convert file into to json then send as response to api endpoint that it has access
-func (app *application) sendToAPI(info filetrack.FileInfo) error {
-	//file info to json
-	jsonData, err := app.JSON(info)
+// headers of the hmac signature, the signature covers the timestamp, a dot and the body as sent
+const (
+	signatureHeader          = "X-Signature"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// batch formats selectable with the batch_format config key
+const (
+	batchSingle = "single"
+	batchJSON   = "json"
+	batchNDJSON = "ndjson"
+)
+
+// endpointFor - api endpoint of the watch target the path belongs to
+func (app *application) endpointFor(path string) string {
+	cfg := app.currentConfig()
+	if target, ok := scan.Owner(cfg.Watches, path); ok {
+		return target.APIEndpoint
+	}
+
+	return cfg.APIEndpoint
+}
+
+// encodeBatch - body and content type for a batch of json records in the configured batch format
+func (app *application) encodeBatch(records []json.RawMessage) ([]byte, string, error) {
+	var body bytes.Buffer
+
+	switch app.currentConfig().BatchFormat {
+	case batchNDJSON:
+		// one compact record per line
+		for _, record := range records {
+			if err := json.Compact(&body, record); err != nil {
+				return nil, "", fmt.Errorf("error encoding batch: %w", err)
+			}
+			body.WriteByte('\n')
+		}
+		return body.Bytes(), "application/x-ndjson", nil
+	case batchJSON:
+		js, err := json.Marshal(records)
+		if err != nil {
+			return nil, "", fmt.Errorf("error encoding batch: %w", err)
+		}
+		return js, "application/json", nil
+	default:
+		if len(records) != 1 {
+			return nil, "", fmt.Errorf("single format sends one record per request, got %d", len(records))
+		}
+		return records[0], "application/json", nil
+	}
+}
+
+// postToAPI - post a body to an api endpoint, gzip compressed when batch_gzip is set
+func (app *application) postToAPI(endpoint string, body []byte, contentType string) error {
+	gzipBody := app.currentConfig().BatchGzip
+	if gzipBody {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(body); err != nil {
+			return fmt.Errorf("error compressing body: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("error compressing body: %w", err)
+		}
+		body = compressed.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("error converting file info to JSON: %w", err)
+		return fmt.Errorf("error creating POST request: %w", err)
 	}
+	req.Header.Set("Content-Type", contentType)
+	if gzipBody {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	app.authorizeRequest(req, body, time.Now())
 
 	// use httpClient to send a post response to api endpoint
-	resp, err := app.httpClient.Post(app.config.APIEndpoint, "application/json", bytes.NewBuffer(jsonData))
+	start := time.Now()
+	resp, err := app.httpClient.Do(req)
 	if err != nil {
+		app.metrics.delivered(start, err)
 		return fmt.Errorf("error sending POST request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned non-200 status code: %d", resp.StatusCode)
+		err = fmt.Errorf("API returned non-200 status code: %d", resp.StatusCode)
+		app.metrics.delivered(start, err)
+		return err
 	}
 
+	app.metrics.delivered(start, nil)
 	return nil
 }
+
+// authorizeRequest - add the bearer token and the hmac signature when they are configured
+func (app *application) authorizeRequest(req *http.Request, body []byte, now time.Time) {
+	cfg := app.currentConfig()
+	if cfg.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIToken)
+	}
+
+	if cfg.APIHMACSecret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(signatureTimestampHeader, timestamp)
+		req.Header.Set(signatureHeader, "sha256="+signBody(cfg.APIHMACSecret, timestamp, body))
+	}
+}
+
+// signBody - hex hmac-sha256 of the timestamp and body, the timestamp lets the receiver reject replays
+func signBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
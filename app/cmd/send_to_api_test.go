@@ -1,16 +1,24 @@
 package main
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"github.com/thespider911/filetrackermodification/app/internal/config"
 	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/outbox"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-func TestSendToAPI(t *testing.T) {
+// TestDeliverBatch - a change event queued in the outbox is posted as it was queued and acknowledged,
+// a failed post stays in the outbox
+func TestDeliverBatch(t *testing.T) {
 	// create a mock HTTP server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -41,11 +49,20 @@ func TestSendToAPI(t *testing.T) {
 	}))
 	defer server.Close()
 
+	box, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"), outbox.Options{MaxAttempts: 3, BaseDelay: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	defer box.Close()
+
 	// create a mock application
 	app := &application{
+		errorLog:   log.New(io.Discard, "", 0),
 		httpClient: http.DefaultClient,
+		outbox:     box,
 		config: config.Config{
 			APIEndpoint: server.URL,
+			BatchFormat: batchSingle,
 		},
 	}
 
@@ -60,10 +77,30 @@ func TestSendToAPI(t *testing.T) {
 		Permission:   "rw-r--r--",
 	}
 
-	// call the sendToAPI function
-	err := app.sendToAPI(mockInfo)
-	if err != nil {
-		t.Errorf("sendToAPI returned an error: %v", err)
+	// wrap it in a change event, the file info fields stay at the top level
+	mockEvent := snapshot.Event{Type: snapshot.Created, FileInfo: mockInfo}
+
+	// queue the event the way publishEvents does and deliver it
+	deliver := func() error {
+		jsonData, err := app.JSON(mockEvent)
+		if err != nil {
+			t.Fatalf("Error converting event to JSON: %v", err)
+		}
+		if err := box.Enqueue(jsonData, app.endpointFor(mockEvent.Path)); err != nil {
+			t.Fatalf("Failed to enqueue the event: %v", err)
+		}
+		items, err := box.Due(time.Now(), 1)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("Expected the event to be due, got %v (%v)", items, err)
+		}
+		return app.deliverBatch(items)
+	}
+
+	if err := deliver(); err != nil {
+		t.Errorf("deliverBatch returned an error: %v", err)
+	}
+	if stats, _ := box.Stats(); stats.Depth != 0 {
+		t.Errorf("Expected the delivered event to be acknowledged, got depth %d", stats.Depth)
 	}
 
 	// test with server returning non-200 status
@@ -74,10 +111,112 @@ func TestSendToAPI(t *testing.T) {
 	defer server.Close()
 
 	app.config.APIEndpoint = server.URL
-	err = app.sendToAPI(mockInfo)
+	err = deliver()
 	if err == nil {
 		t.Error("Expected an error when server returns non-200 status, but got nil")
 	} else if err.Error() != "API returned non-200 status code: 500" {
 		t.Errorf("Expected error 'API returned non-200 status code: 500', got '%v'", err)
 	}
+	if stats, _ := box.Stats(); stats.Depth != 1 {
+		t.Errorf("Expected the failed event to stay in the outbox, got depth %d", stats.Depth)
+	}
+}
+
+func TestPostBatch(t *testing.T) {
+	records := []json.RawMessage{
+		json.RawMessage("{\n\t\"filename\": \"one.txt\"\n}"),
+		json.RawMessage("{\n\t\"filename\": \"two.txt\"\n}"),
+	}
+
+	tests := []struct {
+		format      string
+		gzip        bool
+		contentType string
+		expected    string
+	}{
+		{batchJSON, false, "application/json", `[{"filename":"one.txt"},{"filename":"two.txt"}]`},
+		{batchNDJSON, false, "application/x-ndjson", "{\"filename\":\"one.txt\"}\n{\"filename\":\"two.txt\"}\n"},
+		{batchNDJSON, true, "application/x-ndjson", "{\"filename\":\"one.txt\"}\n{\"filename\":\"two.txt\"}\n"},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != test.contentType {
+				t.Errorf("%s: expected Content-Type %s, got %s", test.format, test.contentType, r.Header.Get("Content-Type"))
+			}
+
+			var body io.Reader = r.Body
+			if test.gzip {
+				if r.Header.Get("Content-Encoding") != "gzip" {
+					t.Errorf("%s: expected gzip Content-Encoding", test.format)
+				}
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Fatalf("%s: error reading gzip body: %v", test.format, err)
+				}
+				body = zr
+			}
+
+			data, _ := io.ReadAll(body)
+			if string(data) != test.expected {
+				t.Errorf("%s: expected body %q, got %q", test.format, test.expected, string(data))
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		app := &application{
+			httpClient: http.DefaultClient,
+			config: config.Config{
+				APIEndpoint: server.URL,
+				BatchFormat: test.format,
+				BatchGzip:   test.gzip,
+			},
+		}
+
+		body, contentType, err := app.encodeBatch(records)
+		if err != nil {
+			t.Fatalf("%s: encodeBatch returned an error: %v", test.format, err)
+		}
+		if err := app.postToAPI(server.URL, body, contentType); err != nil {
+			t.Errorf("%s: postToAPI returned an error: %v", test.format, err)
+		}
+		server.Close()
+	}
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+	app := &application{
+		config: config.Config{
+			APIToken:      "token",
+			APIHMACSecret: "secret",
+		},
+	}
+
+	body := []byte(`{"filename":"one.txt"}`)
+	req := httptest.NewRequest(http.MethodPost, "/file-endpoint", nil)
+	app.authorizeRequest(req, body, time.Unix(1700000000, 0))
+
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("Expected bearer token, got %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get(signatureTimestampHeader) != "1700000000" {
+		t.Errorf("Expected timestamp 1700000000, got %q", req.Header.Get(signatureTimestampHeader))
+	}
+
+	// echo -n '1700000000.{"filename":"one.txt"}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=847d67f99e0c7d6e78512201047f98d98ad671bab982bb68e020020adffb6a8c"
+	if req.Header.Get(signatureHeader) != expected {
+		t.Errorf("Expected signature %s, got %s", expected, req.Header.Get(signatureHeader))
+	}
+	if signBody("secret", "1700000001", body) == signBody("secret", "1700000000", body) {
+		t.Error("Expected the signature to change with the timestamp")
+	}
+
+	// nothing is added when authentication is not configured
+	app.config = config.Config{}
+	req = httptest.NewRequest(http.MethodPost, "/file-endpoint", nil)
+	app.authorizeRequest(req, body, time.Now())
+	if req.Header.Get("Authorization") != "" || req.Header.Get(signatureHeader) != "" {
+		t.Errorf("Expected no auth headers, got %v", req.Header)
+	}
 }
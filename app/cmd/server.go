@@ -2,23 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
-	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/tlsutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
 
 func (app *application) serveHttp() error {
+	// the startup keys, read once so a reload swapping the config does not race with them
+	cfg := app.currentConfig()
+
 	//serve http - addr, routes,
 	srv := http.Server{
-		Addr:     fmt.Sprintf(":%d", app.config.HttpPort),
+		Addr:     net.JoinHostPort(cfg.HttpHost, strconv.Itoa(cfg.HttpPort)),
 		Handler:  app.routes(),
 		ErrorLog: app.errorLog,
 	}
 
+	// Shutdown waits for the open requests but does not cancel them, the streams only end when told to
+	srv.RegisterOnShutdown(app.stream.close)
+
 	// done channel to signal when all cleanup is complete
 	done := make(chan bool)
 
@@ -51,13 +61,28 @@ func (app *application) serveHttp() error {
 		close(done)
 	}()
 
-	app.infoLog.Printf("Starting server on port %d", app.config.HttpPort)
+	// https with the configured or a generated certificate
+	if cfg.TLSEnabled {
+		tlsConfig, err := app.tlsConfig(cfg)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	app.infoLog.Printf("Starting server on port %d", cfg.HttpPort)
 
 	// start the service (this should start worker and timer threads)
 	app.startService()
 
-	// start the server
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	// start the server, the certificate comes from TLSConfig
+	var err error
+	if cfg.TLSEnabled {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
@@ -67,3 +92,36 @@ func (app *application) serveHttp() error {
 
 	return nil
 }
+
+// tlsConfig - tls settings for the server, the certificate is reloaded when its files change
+func (app *application) tlsConfig(cfg config.Config) (*tls.Config, error) {
+	generated, err := tlsutil.EnsureSelfSigned(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if generated {
+		app.infoLog.Printf("Generated self-signed certificate %s\n", cfg.TLSCertFile)
+	}
+
+	reloader, err := tlsutil.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	// client certificates are verified when sent, requireClientCert makes them mandatory per route
+	if cfg.TLSClientCAFile != "" {
+		pool, err := tlsutil.LoadCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
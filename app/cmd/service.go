@@ -2,8 +2,8 @@ package main
 
 import (
 	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
 	"os"
-	"path/filepath"
 	"time"
 )
 
@@ -38,6 +38,26 @@ func (app *application) startService() {
 		}
 	}()
 
+	// Start deliveryThread
+	go func() {
+		app.appendLog("Delivery thread starting...\n")
+		if err := app.deliveryThread(); err != nil {
+			app.errorLog.Printf("Delivery thread error: %v\n", err)
+			app.appendLog(fmt.Sprintf("Delivery thread error: %v\n", err))
+		}
+	}()
+
+	// Start watcherThread
+	if app.currentConfig().WatchMode {
+		go func() {
+			app.appendLog("Watcher thread starting...\n")
+			if err := app.watcherThread(); err != nil {
+				app.errorLog.Printf("Watcher thread error: %v\n", err)
+				app.appendLog(fmt.Sprintf("Watcher thread error: %v\n", err))
+			}
+		}()
+	}
+
 	app.appendLog("Service started.\n")
 
 	// Trigger an immediate check of the directory
@@ -82,27 +102,38 @@ func (app *application) stopService() {
 	app.appendLog("Service stopped.\n")
 }
 
-// initialDirectoryCheck -  check the directory if exists
+// initialDirectoryCheck -  check the watch targets if exist
 func (app *application) initialDirectoryCheck() {
 	app.appendLog("Starting initial directory check...\n")
-	err := filepath.Walk(app.config.Directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			app.appendLog(fmt.Sprintf("Error accessing path %s: %v\n", path, err))
-			return err
-		}
+	watches := app.currentConfig().Watches
+	results := make([]scanResult, 0, len(watches))
+	for _, target := range watches {
+		seen := make(map[string]struct{})
+		owned := app.ownedBy(target)
+		err := scan.Walk(target, target.Path, func(path string, info os.FileInfo) error {
+			if info.IsDir() || !owned(path) {
+				return nil
+			}
 
-		if !info.IsDir() {
+			seen[path] = struct{}{}
 			app.appendLog(fmt.Sprintf("Queueing file for check: %s\n", path))
 			app.commandQueue <- Command{
 				Type: "CHECK_DIRECTORY_FILES",
 				Data: path,
 			}
+			return nil
+		})
+		if err != nil {
+			app.errorLog.Printf("Error in initial directory walk of %s: %v\n", target.Path, err)
+			app.appendLog(fmt.Sprintf("Error in initial directory walk of %s: %v\n", target.Path, err))
+			continue
 		}
-		return nil
-	})
-	if err != nil {
-		app.errorLog.Printf("Error in initial directory walk: %v\n", err)
-		app.appendLog(fmt.Sprintf("Error in initial directory walk: %v\n", err))
+		results = append(results, scanResult{target: target, seen: seen})
+	}
+
+	// the first sweeps prime the snapshot, later scans report created files
+	for _, result := range results {
+		app.commandQueue <- Command{Type: "SCAN_COMPLETE", Data: result}
 	}
 	app.appendLog("Initial directory check completed.\n")
 }
@@ -118,12 +149,9 @@ func (app *application) appendLog(text string) {
 	}
 }
 
-// updateLogs - to the fynne UI
-func (app *application) updateLogs() {
+// printLogs - write the ui logs to the info log when there is no window
+func (app *application) printLogs() {
 	for text := range app.logChan {
-		app.uiLogs.SetText(app.uiLogs.Text + text)
-		app.uiLogs.CursorRow = len(app.uiLogs.Text)
-		// Also log to the console for debugging
-		//fmt.Print(text)
+		app.infoLog.Print(text)
 	}
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamHeartbeat - how often an idle stream sends a comment to keep the connection open
+const streamHeartbeat = 15 * time.Second
+
+// eventBroker - fans the stored events out to the /stream subscribers
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan eventstore.Record]struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// newEventBroker - broker without subscribers
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan eventstore.Record]struct{}), done: make(chan struct{})}
+}
+
+// close - end every stream, called when the server shuts down since that does not cancel the open requests
+func (b *eventBroker) close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// closed - channel closed when the streams have to end
+func (b *eventBroker) closed() <-chan struct{} {
+	return b.done
+}
+
+// subscribe - channel receiving every published event until unsubscribe
+func (b *eventBroker) subscribe() chan eventstore.Record {
+	ch := make(chan eventstore.Record, 100)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+// unsubscribe - stop sending events to the channel
+func (b *eventBroker) unsubscribe(ch chan eventstore.Record) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// publish - send the event to every subscriber, a subscriber that is too slow misses it
+// and can catch up by reconnecting with its last event id
+func (b *eventBroker) publish(record eventstore.Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- record:
+		default:
+		}
+	}
+}
+
+// streamFilter - server side filters of a stream
+type streamFilter struct {
+	glob  string
+	types map[snapshot.EventType]bool
+}
+
+// match - check the event path against the glob and its type against the types
+func (f streamFilter) match(record eventstore.Record) bool {
+	if len(f.types) > 0 && !f.types[record.Type] {
+		return false
+	}
+	if f.glob == "" {
+		return true
+	}
+
+	return scan.MatchGlob(f.glob, record.Path)
+}
+
+/*
+	streamHandler
+
+- server-sent events stream of the change events as the worker publishes them
+- filters: path (glob where ** spans directories), type (comma separated)
+- resume: cursor, or the Last-Event-ID header EventSource sends on reconnect, replays the stored events after that id first
+*/
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverError(w, r, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	query := r.URL.Query()
+	filter := streamFilter{glob: query.Get("path")}
+	if !scan.ValidGlob(filter.glob) {
+		app.badRequest(w, r, fmt.Errorf("path must be a valid glob"))
+		return
+	}
+	if types := query.Get("type"); types != "" {
+		filter.types = make(map[snapshot.EventType]bool)
+		for _, eventType := range strings.Split(types, ",") {
+			filter.types[snapshot.EventType(strings.TrimSpace(eventType))] = true
+		}
+	}
+
+	cursor := query.Get("cursor")
+	if cursor == "" {
+		cursor = r.Header.Get("Last-Event-ID")
+	}
+	var lastID uint64
+	if cursor != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			app.badRequest(w, r, fmt.Errorf("cursor must be an event id"))
+			return
+		}
+	}
+
+	// subscribe before replaying so nothing published in between is missed
+	events := app.stream.subscribe()
+	defer app.stream.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if cursor != "" {
+		for {
+			records, err := app.events.Events(eventstore.Filter{After: lastID, Limit: 500})
+			if err != nil {
+				app.errorLog.Printf("Error replaying events: %v\n", err)
+				return
+			}
+			for _, record := range records {
+				if err := app.writeStreamEvent(w, filter, record); err != nil {
+					return
+				}
+				lastID = record.ID
+			}
+			flusher.Flush()
+
+			if len(records) < 500 {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case record := <-events:
+			// already sent by the replay
+			if record.ID <= lastID {
+				continue
+			}
+			if err := app.writeStreamEvent(w, filter, record); err != nil {
+				return
+			}
+			lastID = record.ID
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-app.stream.closed():
+			return
+		}
+	}
+}
+
+// writeStreamEvent - write a record matching the filter as a server-sent event with its id
+func (app *application) writeStreamEvent(w http.ResponseWriter, filter streamFilter, record eventstore.Record) error {
+	if !filter.match(record) {
+		return nil
+	}
+
+	js, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", record.ID, record.Type, js)
+	return err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+)
+
+// TestStreamHandler - stored events after the cursor are replayed, then live events follow, both filtered
+func TestStreamHandler(t *testing.T) {
+	events, err := eventstore.Open(filepath.Join(t.TempDir(), "events.db"), eventstore.Options{})
+	if err != nil {
+		t.Fatalf("Failed to open event store: %v", err)
+	}
+	defer events.Close()
+
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		events:   events,
+		stream:   newEventBroker(),
+	}
+
+	add := func(eventType snapshot.EventType, path string) {
+		record, err := events.AddEvent(snapshot.Event{Type: eventType, FileInfo: filetrack.FileInfo{Path: path}})
+		if err != nil {
+			t.Fatalf("Failed to add event: %v", err)
+		}
+		app.stream.publish(record)
+	}
+
+	add(snapshot.Created, "/data/before.txt") // id 1, before the cursor
+	add(snapshot.Created, "/data/one.txt")    // id 2, replayed
+	add(snapshot.Deleted, "/data/one.txt")    // id 3, filtered by type
+	add(snapshot.Created, "/other/two.txt")   // id 4, filtered by path
+	add(snapshot.Created, "/data/sub/a.txt")  // id 5, replayed
+
+	server := httptest.NewServer(http.HandlerFunc(app.streamHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?cursor=1&type=created&path=/data/**")
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	ids := make(chan string)
+	ended := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				ids <- id
+			}
+		}
+		close(ended)
+	}()
+
+	expect := func(expected string) {
+		select {
+		case id := <-ids:
+			if id != expected {
+				t.Errorf("Expected event id %s, got %s", expected, id)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for event %s", expected)
+		}
+	}
+
+	expect("2")
+	expect("5")
+
+	// live event published after the replay
+	add(snapshot.Created, "/data/three.txt")
+	expect("6")
+
+	// the server shutting down ends the stream
+	app.stream.close()
+	select {
+	case <-ended:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the stream to end when the broker is closed")
+	}
+}
@@ -1,24 +1,39 @@
 package main
 
 import (
-	"errors"
 	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
 	"os"
-	"path/filepath"
-	"syscall"
+	"sync"
 	"time"
 )
 
+// removeGrace - how long a removed path waits for a matching create before it is reported deleted
+const removeGrace = 2 * time.Second
+
+// scanResult - the files a walk of a watch target found, data of the SCAN_COMPLETE command
+type scanResult struct {
+	target config.WatchTarget
+	seen   map[string]struct{}
+}
+
 /*
 	workerThread
 
 - this is to keep running continuous unless stopped
-- the thread listens to file logs the files info
+- the thread compares each file against the last snapshot and publishes the changes
 - it loops the checking directory files command
 */
 func (app *application) workerThread() error {
 	defer app.appendLog("Worker thread stopped\n")
 
+	// removed paths that were not renamed are reported once the grace period is over
+	flushTicker := time.NewTicker(removeGrace)
+	defer flushTicker.Stop()
+
 	for {
 		select {
 		case cmd, ok := <-app.commandQueue:
@@ -26,7 +41,7 @@ func (app *application) workerThread() error {
 				return nil
 			}
 			switch cmd.Type {
-			// check files command and print file info
+			// check files command and publish what changed
 			case "CHECK_DIRECTORY_FILES":
 				if filePath, ok := cmd.Data.(string); ok {
 					fileInfo, err := app.service.FileTracker.FetchFilesInfo(filePath)
@@ -35,87 +50,237 @@ func (app *application) workerThread() error {
 						continue
 					}
 
-					if fileInfo != nil {
-						// print the result file information
-						if err := app.logFileInfo(*fileInfo); err != nil {
-							app.errorLog.Printf("Error logging file info: %v\n", err)
-							continue
-						}
-
-						// Update UI logs
-						jsonData, err := app.JSON(fileInfo)
-						if err != nil {
-							app.errorLog.Printf("Error marshalling file info to JSON: %v\n", err)
-						} else {
-							// Log the JSON string
-							app.appendLog(fmt.Sprintf("File Info:\n%s", string(jsonData)))
-						}
-
-						//send to api the file info
-						if err := app.sendToAPI(*fileInfo); err != nil {
-							// if the api is not running
-							if errors.Is(err, syscall.ECONNREFUSED) {
-								app.errorLog.Println("API service not running")
-							} else {
-								app.errorLog.Printf("Error sending to API: %v\n", err)
-							}
-						}
+					// the file was removed before the worker got to it
+					if fileInfo == nil {
+						app.snapshots.Remove(filePath)
+						continue
+					}
+
+					// keep the scan result in the history
+					if err := app.events.AddScan(*fileInfo); err != nil {
+						app.errorLog.Printf("Error storing scan result: %v\n", err)
 					}
+
+					events := app.snapshots.Compare(*fileInfo)
+					if violation := app.checkIntegrity(*fileInfo); violation != nil {
+						events = append(events, *violation)
+					}
+					app.publishEvents(events)
+				}
+			// file removed or renamed away, reported by the watcher
+			case "FILE_REMOVED":
+				if filePath, ok := cmd.Data.(string); ok {
+					app.snapshots.Remove(filePath)
+				}
+			// a full walk of a target finished, anything it did not see was deleted
+			case "SCAN_COMPLETE":
+				if result, ok := cmd.Data.(scanResult); ok {
+					app.publishEvents(app.snapshots.SweepFunc(app.ownedBy(result.target), result.seen))
 				}
 			default:
 				app.errorLog.Printf("Unknown command type: %s\n", cmd.Type)
 			}
+		case <-flushTicker.C:
+			app.publishEvents(app.snapshots.Flush(removeGrace))
 		case <-app.serviceStopper:
 			return nil
 		}
 	}
 }
 
+// publishEvents - log change events, show them in the UI and queue them for the api
+func (app *application) publishEvents(events []snapshot.Event) {
+	for _, event := range events {
+		app.metrics.published(event.Type)
+
+		// print the change event
+		if err := app.logEvent(event); err != nil {
+			app.errorLog.Printf("Error logging event: %v\n", err)
+			continue
+		}
+
+		// keep the event in the history and push it to the stream subscribers
+		record, err := app.storeEvent(event)
+		if err != nil {
+			app.errorLog.Printf("Error storing event: %v\n", err)
+		} else {
+			app.stream.publish(record)
+		}
+
+		// Update UI logs
+		jsonData, err := app.JSON(event)
+		if err != nil {
+			app.errorLog.Printf("Error marshalling event to JSON: %v\n", err)
+			continue
+		}
+
+		// Log the JSON string
+		app.appendLog(fmt.Sprintf("File %s:\n%s", event.Type, string(jsonData)))
+
+		//queue the change event for the api, the delivery thread sends it
+		if err := app.outbox.Enqueue(jsonData, app.endpointFor(event.Path)); err != nil {
+			app.errorLog.Printf("Error queueing event for API: %v\n", err)
+		}
+	}
+}
+
+// storeEvent - add the event to the history, a deleted or renamed path gets a tombstone in place of its latest state
+func (app *application) storeEvent(event snapshot.Event) (eventstore.Record, error) {
+	switch event.Type {
+	case snapshot.Deleted:
+		if err := app.events.Remove(event.Path, event.Type, ""); err != nil {
+			return eventstore.Record{}, err
+		}
+	case snapshot.Renamed:
+		if err := app.events.Remove(event.OldPath, event.Type, event.Path); err != nil {
+			return eventstore.Record{}, err
+		}
+	}
+
+	return app.events.AddEvent(event)
+}
+
 /*
-*
-timeThread - this runs every minute checking all files in the specified directory
-- It then calls the commandQueue looping through
+	timerThread
+
+- schedules a walk of every watch target on its own check_interval
+- in watch mode every target is walked each reconcile_interval instead
+- a config reload restarts the schedules with the new targets and walks the added targets straight away
+- returns once every target's schedule stopped with the service
 */
 func (app *application) timerThread() error {
 	defer app.appendLog("Timer thread stopped\n")
 
+	stop := app.serviceStopper
+	var previous []config.WatchTarget
+	for {
+		reloaded := app.configReloaded()
+		targets := app.currentConfig().Watches
+
+		if previous != nil {
+			app.checkDirectory(addedTargets(previous, targets)...)
+		}
+		previous = targets
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for _, target := range targets {
+			wg.Add(1)
+			go func(target config.WatchTarget) {
+				defer wg.Done()
+				app.scheduleTarget(target, done)
+			}(target)
+		}
+
+		select {
+		case <-reloaded:
+			close(done)
+			wg.Wait()
+			app.appendLog("Timer thread restarted with the reloaded config\n")
+		case <-stop:
+			close(done)
+			wg.Wait()
+			return nil
+		}
+	}
+}
+
+// addedTargets - targets whose path was not watched before
+func addedTargets(previous, current []config.WatchTarget) []config.WatchTarget {
+	var added []config.WatchTarget
+	for _, target := range current {
+		if !containsPath(previous, target.Path) {
+			added = append(added, target)
+		}
+	}
+
+	return added
+}
+
+// containsPath - whether one of the targets has the path
+func containsPath(targets []config.WatchTarget, path string) bool {
+	for _, target := range targets {
+		if target.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scheduleTarget - walk the target every interval until stop is closed
+func (app *application) scheduleTarget(target config.WatchTarget, stop chan struct{}) {
 	// Ensure CheckInterval is positive
-	checkInterval := time.Duration(app.config.CheckInterval) * time.Second
+	checkInterval := time.Duration(target.CheckInterval) * time.Second
+
+	// in watch mode the walk only reconciles events the watcher missed
+	cfg := app.currentConfig()
+	if cfg.WatchMode && cfg.ReconcileInterval > 0 {
+		checkInterval = time.Duration(cfg.ReconcileInterval) * time.Second
+	}
 	if checkInterval <= 0 {
 		checkInterval = time.Minute // Default to 1 minute if not set or invalid
-		app.appendLog(fmt.Sprintf("Warning: Invalid CheckInterval (%d). Using default of 1 minute.\n", app.config.CheckInterval))
+		app.appendLog(fmt.Sprintf("Warning: Invalid CheckInterval (%d) for %s. Using default of 1 minute.\n", target.CheckInterval, target.Path))
 	}
 
-	//check this thread every minute
 	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := app.checkDirectory(); err != nil {
-				app.errorLog.Printf("Error checking directory: %v\n", err)
-			}
-		case <-app.serviceStopper:
-			return nil
+			app.checkDirectory(target)
+		case <-stop:
+			return
 		}
 	}
 }
 
-// checkDirectory - check if the directory exists and is accessible
-func (app *application) checkDirectory() error {
-	return filepath.Walk(app.config.Directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// checkDirectory - walk the watch targets and queue the files they track,
+// a target that fails to walk is logged and skipped so the others are still compared and swept
+func (app *application) checkDirectory(targets ...config.WatchTarget) {
+	results := make([]scanResult, 0, len(targets))
+	for _, target := range targets {
+		start := time.Now()
+		seen := make(map[string]struct{})
+		owned := app.ownedBy(target)
+
+		err := scan.Walk(target, target.Path, func(path string, info os.FileInfo) error {
+			// the files of a nested target are checked by its own walk
+			if info.IsDir() || !owned(path) {
+				return nil
+			}
 
-		if !info.IsDir() {
+			seen[path] = struct{}{}
 			select {
 			case app.commandQueue <- Command{Type: "CHECK_DIRECTORY_FILES", Data: path}:
 			default:
+				app.metrics.commandDropped()
 				app.errorLog.Printf("Command queue is full, skipping file: %s\n", path)
 			}
+			return nil
+		})
+		if err != nil {
+			app.metrics.scanFailed(start)
+			app.errorLog.Printf("Error checking directory %s: %v\n", target.Path, err)
+			continue
 		}
-		return nil
-	})
+		app.metrics.scanned(target.Path, start, len(seen))
+
+		results = append(results, scanResult{target: target, seen: seen})
+	}
+
+	// queued after the files of every target so the sweeps run once they are compared
+	for _, result := range results {
+		app.queueCommand(Command{Type: "SCAN_COMPLETE", Data: result})
+	}
+}
+
+// ownedBy - whether a path belongs to the target rather than to a target nested inside it
+func (app *application) ownedBy(target config.WatchTarget) func(path string) bool {
+	watches := app.currentConfig().Watches
+	return func(path string) bool {
+		owner, ok := scan.Owner(watches, path)
+		return ok && owner.Path == target.Path
+	}
 }
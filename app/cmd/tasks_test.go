@@ -2,6 +2,8 @@ package main
 
 import (
 	"errors"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"sync"
@@ -9,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/thespider911/filetrackermodification/app/internal/config"
 	"github.com/thespider911/filetrackermodification/app/internal/service"
 	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
 )
@@ -96,7 +99,7 @@ func TestWorkerThread(t *testing.T) {
 		commandQueue:   make(chan Command),
 		serviceStopper: make(chan struct{}),
 		errorLog:       &MockLogger{},
-		service:        service.NewService(),
+		service:        service.NewService(config.Config{}, "", nil),
 	}
 
 	mockFileTracker := MockFileTracker{
@@ -132,7 +135,7 @@ func TestWorkerThreadAPIError(t *testing.T) {
 		commandQueue:   make(chan Command),
 		serviceStopper: make(chan struct{}),
 		errorLog:       &MockLogger{},
-		service:        service.NewService(),
+		service:        service.NewService(config.Config{}, "", nil),
 	}
 
 	mockFileTracker := MockFileTracker{
@@ -208,3 +211,40 @@ func TestTimerThread(t *testing.T) {
 		t.Error("Timed out waiting for command")
 	}
 }
+
+// TestCheckDirectory - a target that cannot be walked does not stop the scan of the others
+func TestCheckDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	missing := config.WatchTarget{Path: filepath.Join(dir, "missing")}
+	present := config.WatchTarget{Path: dir}
+	app := &application{
+		errorLog:     log.New(io.Discard, "", 0),
+		commandQueue: make(chan Command, 10),
+		config:       config.Config{Watches: []config.WatchTarget{missing, present}},
+	}
+	app.metrics = newAppMetrics(app)
+
+	app.checkDirectory(missing, present)
+
+	var files, complete int
+	for len(app.commandQueue) > 0 {
+		switch cmd := <-app.commandQueue; cmd.Type {
+		case "CHECK_DIRECTORY_FILES":
+			files++
+		case "SCAN_COMPLETE":
+			complete++
+			if result := cmd.Data.(scanResult); result.target.Path != dir || len(result.seen) != 2 {
+				t.Errorf("Expected the sweep of %s with 2 files, got %+v", dir, result)
+			}
+		}
+	}
+	if files != 2 || complete != 1 {
+		t.Errorf("Expected 2 files and 1 sweep queued, got %d and %d", files, complete)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
+	"os"
+)
+
+/*
+	watcherThread
+
+- runs when watch_mode is enabled, next to the timer thread
+- only queues paths that fsnotify reports as created, written, chmod'ed, renamed or removed
+- every watch target is watched within its max depth, files its patterns exclude are ignored
+- new subdirectories are watched as they appear
+- the watches are set up again when a config reload swaps the targets
+*/
+func (app *application) watcherThread() error {
+	defer app.appendLog("Watcher thread stopped\n")
+
+	stop := app.serviceStopper
+	for {
+		reloaded := app.configReloaded()
+		restart, err := app.watchTargets(stop, reloaded)
+		if err != nil || !restart {
+			return err
+		}
+		app.appendLog("Watcher thread restarted with the reloaded config\n")
+	}
+}
+
+// watchTargets - watch the current targets until stop is closed, or reloaded is and the targets must be watched again
+func (app *application) watchTargets(stop chan struct{}, reloaded <-chan struct{}) (bool, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return false, fmt.Errorf("error creating watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, target := range app.currentConfig().Watches {
+		if err := app.addWatches(watcher, target, target.Path, false); err != nil {
+			return false, err
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return false, nil
+			}
+			app.handleWatchEvent(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return false, nil
+			}
+			app.errorLog.Printf("Watcher error: %v\n", err)
+		case <-reloaded:
+			return true, nil
+		case <-stop:
+			return false, nil
+		}
+	}
+}
+
+// handleWatchEvent - turn an fsnotify event into a queued command
+func (app *application) handleWatchEvent(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	target, ok := scan.Owner(app.currentConfig().Watches, event.Name)
+	if !ok {
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// the path is gone, a rename also sends a create for the new name
+		app.queueCommand(Command{Type: "FILE_REMOVED", Data: event.Name})
+	case event.Has(fsnotify.Create):
+		info, err := os.Lstat(event.Name)
+		if err != nil {
+			return
+		}
+
+		if info.IsDir() {
+			// files can land in the new directory before the watch is added, so queue them too
+			if err := app.addWatches(watcher, target, event.Name, true); err != nil {
+				app.errorLog.Printf("Error watching directory %s: %v\n", event.Name, err)
+			}
+			return
+		}
+		if scan.Match(target, event.Name, info) {
+			app.queueCommand(Command{Type: "CHECK_DIRECTORY_FILES", Data: event.Name})
+		}
+	case event.Has(fsnotify.Write), event.Has(fsnotify.Chmod):
+		if scan.Match(target, event.Name, nil) {
+			app.queueCommand(Command{Type: "CHECK_DIRECTORY_FILES", Data: event.Name})
+		}
+	}
+}
+
+// addWatches - watch root and every directory of the target below it, optionally queueing the files found
+func (app *application) addWatches(watcher *fsnotify.Watcher, target config.WatchTarget, root string, queueFiles bool) error {
+	return scan.Walk(target, root, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				return fmt.Errorf("error watching %s: %w", path, err)
+			}
+		} else if queueFiles && app.ownedBy(target)(path) {
+			app.queueCommand(Command{Type: "CHECK_DIRECTORY_FILES", Data: path})
+		}
+		return nil
+	})
+}
+
+// queueCommand - add a command to the queue without blocking the caller
+func (app *application) queueCommand(cmd Command) {
+	select {
+	case app.commandQueue <- cmd:
+	default:
+		app.metrics.commandDropped()
+		app.errorLog.Printf("Command queue is full, skipping %s: %v\n", cmd.Type, cmd.Data)
+	}
+}
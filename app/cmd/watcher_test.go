@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// TestWatcherThread - files written in the tree and in new subdirectories are queued
+func TestWatcherThread(t *testing.T) {
+	app := &application{
+		errorLog:       log.New(io.Discard, "", 0),
+		config:         config.Config{Watches: []config.WatchTarget{{Path: t.TempDir()}}},
+		commandQueue:   make(chan Command, 10),
+		serviceStopper: make(chan struct{}),
+		logChan:        make(chan string, 100),
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- app.watcherThread()
+	}()
+
+	// give the watcher time to add its watches
+	time.Sleep(100 * time.Millisecond)
+
+	subDir := filepath.Join(app.config.Watches[0].Path, "sub")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create sub directory: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	testFile := filepath.Join(subDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	select {
+	case cmd := <-app.commandQueue:
+		if cmd.Type != "CHECK_DIRECTORY_FILES" || cmd.Data.(string) != testFile {
+			t.Errorf("Expected CHECK_DIRECTORY_FILES for %s, got %s for %v", testFile, cmd.Type, cmd.Data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for command")
+	}
+
+	if err := os.Remove(testFile); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+
+	// skip the write events for the file, then expect the removal
+	timeout := time.After(3 * time.Second)
+	for removed := false; !removed; {
+		select {
+		case cmd := <-app.commandQueue:
+			removed = cmd.Type == "FILE_REMOVED" && cmd.Data.(string) == testFile
+		case <-timeout:
+			t.Fatal("Timed out waiting for FILE_REMOVED command")
+		}
+	}
+
+	close(app.serviceStopper)
+	if err := <-done; err != nil {
+		t.Errorf("watcherThread returned an error: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/tlsutil"
+)
+
+// apiError - error response of the api, the body is {"Error": message} with an optional "Code"
+type apiError struct {
+	Status  int
+	Message string
+	Code    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// tlsOptions - server verification and client certificate for an https api
+type tlsOptions struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	insecure bool
+}
+
+// client - calls the control http api with an api key
+type client struct {
+	base   string
+	apiKey string
+	http   *http.Client
+	stream *http.Client
+}
+
+// newClient - client for the api at addr
+func newClient(addr, apiKey string, options tlsOptions) (*client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.caFile != "" || options.certFile != "" || options.insecure {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: options.insecure}
+		if options.caFile != "" {
+			pool, err := tlsutil.LoadCertPool(options.caFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if options.certFile != "" {
+			cert, err := tls.LoadX509KeyPair(options.certFile, options.keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error loading client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &client{
+		base:   strings.TrimRight(addr, "/"),
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		stream: &http.Client{Transport: transport},
+	}, nil
+}
+
+// get - GET path and decode the json response, nil for an empty body
+func (c *client) get(path string, query url.Values) (interface{}, error) {
+	return c.do(http.MethodGet, path, query)
+}
+
+// post - POST path and decode the json response, nil for an empty body
+func (c *client) post(path string, query url.Values) (interface{}, error) {
+	return c.do(http.MethodPost, path, query)
+}
+
+func (c *client) do(method, path string, query url.Values) (interface{}, error) {
+	resp, err := c.send(c.http, method, path, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return normalize(data), nil
+}
+
+// follow - read the server-sent events of path, calling fn with the data of every event
+func (c *client) follow(path string, query url.Values, fn func(data []byte) error) error {
+	resp, err := c.send(c.stream, http.MethodGet, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var data bytes.Buffer
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// a blank line ends the event, heartbeats have no data
+			if data.Len() > 0 {
+				if err := fn(data.Bytes()); err != nil {
+					return err
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+
+	return nil
+}
+
+// send - make the request, an error status is returned as an apiError
+func (c *client) send(httpClient *http.Client, method, path string, query url.Values) (*http.Response, error) {
+	target := c.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", c.base, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		var message struct {
+			Error string
+			Code  string
+		}
+		if err := json.Unmarshal(body, &message); err != nil || message.Error == "" {
+			message.Error = strings.TrimSpace(string(body))
+		}
+		return nil, &apiError{Status: resp.StatusCode, Message: message.Error, Code: message.Code}
+	}
+
+	return resp, nil
+}
+
+// normalize - turn the json numbers into int64 or float64 so every output format prints them as numbers
+func normalize(data interface{}) interface{} {
+	switch value := data.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = normalize(v)
+		}
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalize(v)
+		}
+	}
+
+	return data
+}
+
+// isAPIError - whether err is an error response of the api, and its status
+func isAPIError(err error) (int, bool) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status, true
+	}
+
+	return 0, false
+}
+
+// isAuthError - whether err rejects the api key, a 403 with a code denies the path rather than the key
+func isAuthError(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.Status == http.StatusUnauthorized || (apiErr.Status == http.StatusForbidden && apiErr.Code == "")
+}
@@ -0,0 +1,266 @@
+// ftctl - command-line client for the file tracker control api
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// exit codes
+const (
+	exitOK          = 0
+	exitAPIError    = 1 // the api answered with an error
+	exitUsage       = 2
+	exitAuth        = 3 // the api key is missing, unknown or lacks the role
+	exitUnavailable = 4 // the api could not be reached
+)
+
+// event columns of the table output of logs
+var eventColumns = []string{"event_time", "event", "path"}
+
+// cli - a parsed command line
+type cli struct {
+	client *client
+	format string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run - parse the global flags and run the subcommand, returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ftctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", envOr("FTCTL_ADDR", "http://localhost:4000"), "address of the file tracker api, or FTCTL_ADDR")
+	apiKey := flags.String("api-key", os.Getenv("FTCTL_API_KEY"), "api key, or FTCTL_API_KEY")
+	format := flags.String("o", formatTable, "output format: table, json or yaml")
+	caFile := flags.String("ca", "", "CA certificate to verify the api with")
+	certFile := flags.String("cert", "", "client certificate, for an api that requires one on /execute")
+	keyFile := flags.String("cert-key", "", "key of the client certificate")
+	insecure := flags.Bool("insecure", false, "do not verify the api certificate, for a self-signed one")
+	flags.Usage = func() { usage(stderr, flags) }
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	switch *format {
+	case formatTable, formatJSON, formatYAML:
+	default:
+		fmt.Fprintf(stderr, "unknown output format: %s\n", *format)
+		return exitUsage
+	}
+
+	c, err := newClient(*addr, *apiKey, tlsOptions{caFile: *caFile, certFile: *certFile, keyFile: *keyFile, insecure: *insecure})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	cmd := &cli{client: c, format: *format, stdout: stdout, stderr: stderr}
+	return cmd.run(flags.Arg(0), flags.Args()[1:])
+}
+
+// run - run a subcommand
+func (c *cli) run(name string, args []string) int {
+	switch name {
+	case "status":
+		data, err := c.client.get("/health", nil)
+		return c.print(data, err, nil)
+	case "start":
+		_, err := c.client.post("/start", nil)
+		return c.print(map[string]interface{}{"status": "service started"}, err, nil)
+	case "stop":
+		_, err := c.client.post("/stop", nil)
+		return c.print(map[string]interface{}{"status": "service stopped"}, err, nil)
+	case "logs":
+		return c.logs(args)
+	case "help":
+		return c.help(args)
+	case "exec":
+		if len(args) == 0 {
+			fmt.Fprintln(c.stderr, "usage: ftctl exec COMMAND [path] [key=value ...]")
+			return exitUsage
+		}
+		return c.execute(strings.ToUpper(args[0]), args[1:])
+	default:
+		return c.execute(commandName(name), args)
+	}
+}
+
+// logs - the logged change events, with -f the new events as they happen
+func (c *cli) logs(args []string) int {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	follow := flags.Bool("f", false, "follow the new events")
+	path := flags.String("path", "", "only events of paths matching this glob, with -f")
+	types := flags.String("type", "", "only these event types, comma separated, with -f")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	data, err := c.client.get("/logs", nil)
+	if code := c.print(data, err, eventColumns); code != exitOK || !*follow {
+		return code
+	}
+
+	query := url.Values{}
+	if *path != "" {
+		query.Set("path", *path)
+	}
+	if *types != "" {
+		query.Set("type", *types)
+	}
+
+	err = c.client.follow("/stream", query, func(data []byte) error {
+		var record interface{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("error decoding event: %w", err)
+		}
+		return renderRow(c.stdout, c.format, record, eventColumns)
+	})
+	return c.print(nil, err, nil)
+}
+
+// help - the commands /execute runs, or the details of one
+func (c *cli) help(args []string) int {
+	if len(args) > 0 {
+		data, err := c.client.get("/help", url.Values{"command": {commandName(args[0])}})
+		return c.print(data, err, nil)
+	}
+
+	data, err := c.client.get("/help", nil)
+	if err != nil {
+		return c.print(nil, err, nil)
+	}
+
+	// one row per command with the subcommand that runs it
+	commands, _ := data.(map[string]interface{})
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		info, _ := commands[name].(map[string]interface{})
+		rows = append(rows, map[string]interface{}{
+			"command":     name,
+			"subcommand":  subcommandName(name),
+			"description": info["description"],
+			"usage":       info["usage"],
+		})
+	}
+
+	return c.print(rows, nil, []string{"subcommand", "command", "description"})
+}
+
+// execute - run a command on the api, the first argument without = is the path
+func (c *cli) execute(command string, args []string) int {
+	// an unknown subcommand is a usage error rather than a failed command
+	if _, err := c.client.get("/help", url.Values{"command": {command}}); err != nil {
+		if status, ok := isAPIError(err); ok && status == http.StatusNotFound {
+			fmt.Fprintf(c.stderr, "unknown command: %s, see ftctl help\n", subcommandName(command))
+			return exitUsage
+		}
+		return c.print(nil, err, nil)
+	}
+
+	query := url.Values{"command": {command}}
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			query.Set(key, value)
+		} else {
+			query.Set("path", arg)
+		}
+	}
+
+	data, err := c.client.get("/execute", query)
+	return c.print(data, err, nil)
+}
+
+// print - render data, or report err and return the exit code for it
+func (c *cli) print(data interface{}, err error, columns []string) int {
+	if err != nil {
+		fmt.Fprintf(c.stderr, "error: %v\n", err)
+
+		_, ok := isAPIError(err)
+		switch {
+		case !ok:
+			return exitUnavailable
+		case isAuthError(err):
+			return exitAuth
+		default:
+			return exitAPIError
+		}
+	}
+
+	if data == nil {
+		return exitOK
+	}
+	if err := render(c.stdout, c.format, data, columns); err != nil {
+		fmt.Fprintf(c.stderr, "error: %v\n", err)
+		return exitAPIError
+	}
+
+	return exitOK
+}
+
+// commandName - CHECK_FILE_DATES for the subcommand check-file-dates
+func commandName(subcommand string) string {
+	return strings.ToUpper(strings.ReplaceAll(subcommand, "-", "_"))
+}
+
+// subcommandName - check-file-dates for the command CHECK_FILE_DATES
+func subcommandName(command string) string {
+	return strings.ToLower(strings.ReplaceAll(command, "_", "-"))
+}
+
+// envOr - the environment variable, or def when it is not set
+func envOr(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// usage - help text of ftctl
+func usage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprint(w, `Usage: ftctl [flags] <subcommand> [args]
+
+Subcommands:
+  status                      health of the service and its outbox
+  start                       start the service
+  stop                        stop the service
+  logs [-f] [-path glob] [-type types]
+                              logged change events, -f follows new ones
+  help [command]              commands the api runs, or the usage of one
+  exec COMMAND [path] [k=v]   run a command by its api name
+  <command> [path] [k=v]      run a command by its subcommand name, e.g. check-file-dates /path/to/file
+
+Exit codes: 0 ok, 1 api error, 2 usage error, 3 api key rejected, 4 api unreachable
+
+Flags:
+`)
+	flags.PrintDefaults()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAPI - answers like the control api, requiring the api key when one is given
+func testAPI(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey != "" && r.Header.Get("X-API-Key") != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"Error": "A valid api key is required"}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(`{"status": "available", "outbox": {"depth": 3}}`))
+		case "/help":
+			if r.URL.Query().Get("command") == "CHECK_FILE_DATES" || r.URL.Query().Get("command") == "" {
+				w.Write([]byte(`{"CHECK_FILE_DATES": {"name": "CHECK_FILE_DATES", "description": "Checks file times"}}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"Error": "The requested resource could not be found"}`))
+		case "/execute":
+			if r.URL.Query().Get("path") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"Error": "Path is required"}`))
+				return
+			}
+			if r.URL.Query().Get("path") == "/etc/passwd" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"Error": "Invalid path: path is outside the allowed roots", "Code": "outside_allowed_roots"}`))
+				return
+			}
+			w.Write([]byte(`{"path": "` + r.URL.Query().Get("path") + `", "modified_time": 1700000000}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRun(t *testing.T) {
+	server := testAPI(t, "operator-key-0123456789")
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{"status table", []string{"status"}, exitOK, "OUTBOX.DEPTH  3"},
+		{"status json", []string{"-o", "json", "status"}, exitOK, `"depth": 3`},
+		{"status yaml", []string{"-o", "yaml", "status"}, exitOK, "depth: 3"},
+		{"command", []string{"check-file-dates", "/tmp/a.txt"}, exitOK, "MODIFIED_TIME  1700000000"},
+		{"help", []string{"help"}, exitOK, "check-file-dates  CHECK_FILE_DATES  Checks file times"},
+		{"unknown command", []string{"check-nothing"}, exitUsage, ""},
+		{"api error", []string{"exec", "check_file_dates"}, exitAPIError, ""},
+		{"denied path", []string{"check-file-dates", "/etc/passwd"}, exitAPIError, ""},
+		{"bad format", []string{"-o", "xml", "status"}, exitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-addr", server.URL, "-api-key", "operator-key-0123456789"}, tt.args...)
+
+			if code := run(args, &stdout, &stderr); code != tt.code {
+				t.Fatalf("Expected exit code %d, got %d (%s)", tt.code, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.output) {
+				t.Errorf("Expected output to contain %q, got %q", tt.output, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	server := testAPI(t, "operator-key-0123456789")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-addr", server.URL, "status"}, &stdout, &stderr); code != exitAuth {
+		t.Errorf("Expected exit code %d without an api key, got %d", exitAuth, code)
+	}
+
+	// nothing listens on the closed server
+	server.Close()
+	if code := run([]string{"-addr", server.URL, "status"}, &stdout, &stderr); code != exitUnavailable {
+		t.Errorf("Expected exit code %d for an unreachable api, got %d", exitUnavailable, code)
+	}
+}
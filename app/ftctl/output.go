@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// output formats selectable with -o
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// render - write data in the format, a table shows the columns given or every key
+func render(w io.Writer, format string, data interface{}, columns []string) error {
+	switch format {
+	case formatJSON:
+		js, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(js))
+		return err
+	case formatYAML:
+		y, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(y)
+		return err
+	default:
+		return renderTable(w, data, columns)
+	}
+}
+
+// renderTable - a list of objects as rows, an object as key value pairs with nested keys joined by dots
+func renderTable(w io.Writer, data interface{}, columns []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	switch value := data.(type) {
+	case []interface{}:
+		if columns == nil {
+			columns = keysOf(value)
+		}
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+		for _, row := range value {
+			object, _ := row.(map[string]interface{})
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = cell(object[column])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]interface{}:
+		rows := make(map[string]string)
+		flatten("", value, rows)
+
+		keys := make([]string, 0, len(rows))
+		for key := range rows {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(key), rows[key])
+		}
+	default:
+		fmt.Fprintln(tw, cell(value))
+	}
+
+	return tw.Flush()
+}
+
+// renderRow - one row of a followed stream, without alignment since rows arrive one by one
+func renderRow(w io.Writer, format string, data interface{}, columns []string) error {
+	switch format {
+	case formatJSON:
+		js, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(js))
+		return err
+	case formatYAML:
+		if _, err := fmt.Fprintln(w, "---"); err != nil {
+			return err
+		}
+		return render(w, formatYAML, data, nil)
+	default:
+		object, _ := data.(map[string]interface{})
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = cell(object[column])
+		}
+		_, err := fmt.Fprintln(w, strings.Join(cells, "  "))
+		return err
+	}
+}
+
+// flatten - nested object keys joined with dots
+func flatten(prefix string, object map[string]interface{}, rows map[string]string) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, rows)
+			continue
+		}
+		rows[key] = cell(value)
+	}
+}
+
+// keysOf - every key of the objects in the list, sorted
+func keysOf(list []interface{}) []string {
+	seen := make(map[string]struct{})
+	for _, row := range list {
+		if object, ok := row.(map[string]interface{}); ok {
+			for key := range object {
+				seen[key] = struct{}{}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// cell - a value as table text, lists and objects as compact json
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		js, _ := json.Marshal(v)
+		return string(js)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -1,24 +1,130 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"github.com/fsnotify/fsnotify"
 	"github.com/go-playground/validator/v10"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 )
 
+// APIKey - key for the control http api, read keys can only call the read-only endpoints.
+// The name tells the callers apart, e.g. it owns the jobs created with the key.
+type APIKey struct {
+	Name string `mapstructure:"name" validate:"required"`
+	Key  string `mapstructure:"key" validate:"required,min=16"`
+	Role string `mapstructure:"role" validate:"required,oneof=read operator"`
+}
+
+// AllowedRoot - a directory /execute commands may run in, commands limits which ones (all when empty)
+type AllowedRoot struct {
+	Path     string   `mapstructure:"path" validate:"required"`
+	Commands []string `mapstructure:"commands"`
+}
+
+// WatchTarget - a directory tree to track, check_interval and api_endpoint default to the global keys
+type WatchTarget struct {
+	Path          string   `mapstructure:"path" validate:"required,dir"`
+	MaxDepth      int      `mapstructure:"max_depth" validate:"min=0"`
+	CheckInterval int      `mapstructure:"check_interval" validate:"required,min=1"`
+	Include       []string `mapstructure:"include"`
+	Exclude       []string `mapstructure:"exclude"`
+	APIEndpoint   string   `mapstructure:"api_endpoint" validate:"required,url"`
+
+	// filters applied before a file is queued, .gitignore and .trackerignore are honored with ignore_files
+	IgnoreFiles       bool     `mapstructure:"ignore_files"`
+	MaxFileSize       int64    `mapstructure:"max_file_size" validate:"min=0"`
+	Extensions        []string `mapstructure:"extensions"`
+	ExcludeExtensions []string `mapstructure:"exclude_extensions"`
+}
+
 // Config -  and validate
 type Config struct {
+	HttpHost      string `mapstructure:"http_host"`
 	HttpPort      int    `mapstructure:"http_port" validate:"required,min=4000,max=4040"`
-	Directory     string `mapstructure:"directory" validate:"required,dir"`
 	CheckInterval int    `mapstructure:"check_interval" validate:"required,min=1"`
 	APIEndpoint   string `mapstructure:"api_endpoint" validate:"required,url"`
 	QueueSize     int    `mapstructure:"queue_size" validate:"required,min=1"`
+
+	// watched directory trees, a plain directory key is read as a single target
+	Watches []WatchTarget `mapstructure:"watches" validate:"required,min=1,dive"`
+
+	// file tracker backend - osquery (default) or native stat calls
+	TrackerBackend string `mapstructure:"tracker_backend" validate:"required,oneof=osquery native"`
+
+	// watch mode - fsnotify events feed the queue, the periodic walk runs every reconcile_interval
+	WatchMode         bool `mapstructure:"watch_mode"`
+	ReconcileInterval int  `mapstructure:"reconcile_interval" validate:"omitempty,min=1"`
+
+	// content hashing and the approved integrity baseline
+	HashAlgorithm string `mapstructure:"hash_algorithm" validate:"omitempty,oneof=sha256 blake2b"`
+	BaselineFile  string `mapstructure:"baseline_file" validate:"required"`
+
+	// bbolt file holding the scan and event history, keeping at most event_store_max_scans scans per file
+	// and the last event_store_max_events events (0 keeps all)
+	EventStore          string `mapstructure:"event_store" validate:"required"`
+	EventStoreMaxScans  int    `mapstructure:"event_store_max_scans" validate:"min=0"`
+	EventStoreMaxEvents int    `mapstructure:"event_store_max_events" validate:"min=0"`
+
+	// undelivered api payloads, retried with backoff between outbox_retry_base and outbox_retry_max seconds
+	OutboxFile        string `mapstructure:"outbox_file" validate:"required"`
+	OutboxMaxAttempts int    `mapstructure:"outbox_max_attempts" validate:"required,min=1"`
+	OutboxRetryBase   int    `mapstructure:"outbox_retry_base" validate:"required,min=1"`
+	OutboxRetryMax    int    `mapstructure:"outbox_retry_max" validate:"required,gtefield=OutboxRetryBase"`
+
+	// api delivery - single records or batches sent as a json array or ndjson, optionally gzipped
+	BatchFormat     string `mapstructure:"batch_format" validate:"required,oneof=single json ndjson"`
+	BatchMaxRecords int    `mapstructure:"batch_max_records" validate:"required,min=1"`
+	BatchMaxBytes   int    `mapstructure:"batch_max_bytes" validate:"required,min=1"`
+	BatchMaxWait    int    `mapstructure:"batch_max_wait" validate:"min=0"`
+	BatchGzip       bool   `mapstructure:"batch_gzip"`
+
+	// outbound api authentication - bearer token and hmac-sha256 body signature
+	APIToken      string `mapstructure:"api_token"`
+	APIHMACSecret string `mapstructure:"api_hmac_secret"`
+
+	// directories /execute commands may run in, the Desktop directory when none are configured
+	AllowedRoots []AllowedRoot `mapstructure:"allowed_roots" validate:"dive"`
+
+	// raw SELECTs of the OSQUERY command, only on these tables, returning at most osquery_row_limit rows
+	// and stopped after osquery_timeout seconds
+	OsqueryTables   []string `mapstructure:"osquery_tables"`
+	OsqueryRowLimit int      `mapstructure:"osquery_row_limit" validate:"required,min=1"`
+	OsqueryTimeout  int      `mapstructure:"osquery_timeout" validate:"required,min=1"`
+
+	// POST /execute/batch - most items in a batch and how many of them run at a time
+	ExecuteBatchMax    int `mapstructure:"execute_batch_max" validate:"required,min=1"`
+	ExecuteConcurrency int `mapstructure:"execute_concurrency" validate:"required,min=1"`
+
+	// background jobs of /jobs, finished jobs are kept job_retention seconds
+	JobWorkers   int `mapstructure:"job_workers" validate:"required,min=1"`
+	JobQueueSize int `mapstructure:"job_queue_size" validate:"required,min=1"`
+	JobRetention int `mapstructure:"job_retention" validate:"required,min=1"`
+
+	// control http api keys, without any the api is only served when http_host is a loopback address
+	// or insecure_no_auth opens it on purpose
+	APIKeys        []APIKey `mapstructure:"api_keys" validate:"unique=Name,dive"`
+	InsecureNoAuth bool     `mapstructure:"insecure_no_auth"`
+
+	// https for the control api, a self-signed pair is generated when the files do not exist,
+	// with a client CA, which needs tls_enabled, only clients with a certificate it signed can call /execute
+	TLSEnabled      bool   `mapstructure:"tls_enabled"`
+	TLSCertFile     string `mapstructure:"tls_cert_file" validate:"required_if=TLSEnabled true"`
+	TLSKeyFile      string `mapstructure:"tls_key_file" validate:"required_if=TLSEnabled true"`
+	TLSClientCAFile string `mapstructure:"tls_client_ca_file" validate:"excluded_without=TLSEnabled,omitempty,file"`
 }
 
-var config Config
+var (
+	config   Config
+	configMu sync.Mutex
+)
 
 // GetConfig - get config instance pointer
 func GetConfig() (*Config, error) {
@@ -36,34 +142,139 @@ func LoadConfig() error {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 
+	// defaults for optional keys
+	viper.SetDefault("http_host", "127.0.0.1")
+	viper.SetDefault("tracker_backend", "osquery")
+	viper.SetDefault("baseline_file", "baseline.json")
+	viper.SetDefault("event_store", "filetracker.db")
+	viper.SetDefault("event_store_max_scans", 100)
+	viper.SetDefault("event_store_max_events", 100000)
+	viper.SetDefault("outbox_file", "outbox.db")
+	viper.SetDefault("outbox_max_attempts", 10)
+	viper.SetDefault("outbox_retry_base", 2)
+	viper.SetDefault("outbox_retry_max", 300)
+	viper.SetDefault("batch_format", "single")
+	viper.SetDefault("batch_max_records", 500)
+	viper.SetDefault("batch_max_bytes", 1<<20)
+	viper.SetDefault("batch_max_wait", 5)
+	viper.SetDefault("osquery_tables", []string{"file", "hash", "processes", "users", "mounts"})
+	viper.SetDefault("osquery_row_limit", 1000)
+	viper.SetDefault("osquery_timeout", 10)
+	viper.SetDefault("execute_batch_max", 1000)
+	viper.SetDefault("execute_concurrency", 4)
+	viper.SetDefault("job_workers", 2)
+	viper.SetDefault("job_queue_size", 100)
+	viper.SetDefault("job_retention", 3600)
+	viper.SetDefault("tls_cert_file", "tls/server.crt")
+	viper.SetDefault("tls_key_file", "tls/server.key")
+
+	loaded, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	config = loaded
+	configMu.Unlock()
+
+	return nil
+}
+
+// WatchConfig - reload the config file whenever it changes. onChange gets the previous and the new config,
+// or the error when the edit does not load or validate, in which case the previous config stays in use.
+func WatchConfig(onChange func(previous, current Config, err error)) {
+	viper.OnConfigChange(func(fsnotify.Event) {
+		configMu.Lock()
+		previous := config
+		loaded, err := readConfig()
+		if err == nil {
+			config = loaded
+		}
+		configMu.Unlock()
+
+		onChange(previous, loaded, err)
+	})
+	viper.WatchConfig()
+}
+
+// readConfig - read, decode and validate the config file
+func readConfig() (Config, error) {
+	var loaded Config
+
 	if err := viper.ReadInConfig(); err != nil {
-		return fmt.Errorf("error reading config file - %w", err)
+		return loaded, fmt.Errorf("error reading config file - %w", err)
 	}
 
 	// Get the home directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("error getting home directory - %w", err)
+		return loaded, fmt.Errorf("error getting home directory - %w", err)
 	}
 
 	// Replace {{.HomeDir}} placeholder in all string values
-	for key, value := range viper.AllSettings() {
-		if strValue, ok := value.(string); ok {
-			viper.Set(key, strings.Replace(strValue, "{{.HomeDir}}", homeDir, -1))
+	expandHome := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if strValue, ok := data.(string); ok {
+			return strings.Replace(strValue, "{{.HomeDir}}", homeDir, -1), nil
 		}
+		return data, nil
+	}
+
+	err = viper.Unmarshal(&loaded, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		expandHome,
+	)))
+	if err != nil {
+		return loaded, fmt.Errorf("error unmarshalling config - %w", err)
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
-		return fmt.Errorf("error unmarshalling config - %w", err)
+	// the single directory of older config files
+	if len(loaded.Watches) == 0 && viper.IsSet("directory") {
+		directory := strings.Replace(viper.GetString("directory"), "{{.HomeDir}}", homeDir, -1)
+		loaded.Watches = []WatchTarget{{Path: directory}}
 	}
 
-	// Ensure the directory path uses the correct separators for the OS
-	config.Directory = filepath.FromSlash(config.Directory)
+	for i := range loaded.Watches {
+		watch := &loaded.Watches[i]
+
+		// Ensure the directory path uses the correct separators for the OS
+		watch.Path = filepath.FromSlash(watch.Path)
+		if watch.CheckInterval == 0 {
+			watch.CheckInterval = loaded.CheckInterval
+		}
+		if watch.APIEndpoint == "" {
+			watch.APIEndpoint = loaded.APIEndpoint
+		}
+	}
+
+	if len(loaded.AllowedRoots) == 0 {
+		loaded.AllowedRoots = []AllowedRoot{{Path: filepath.Join(homeDir, "Desktop")}}
+	}
+	for i := range loaded.AllowedRoots {
+		loaded.AllowedRoots[i].Path = filepath.FromSlash(loaded.AllowedRoots[i].Path)
+	}
 
 	validate := validator.New()
-	if err := validate.Struct(config); err != nil {
-		return err
+	if err := validate.Struct(loaded); err != nil {
+		return loaded, err
+	}
+	if err := CheckAPIAuth(loaded); err != nil {
+		return loaded, err
 	}
 
-	return nil
+	return loaded, nil
+}
+
+// CheckAPIAuth - refuse a control api without api_keys, unless it only listens on a loopback address
+// or insecure_no_auth is set
+func CheckAPIAuth(cfg Config) error {
+	if len(cfg.APIKeys) > 0 || cfg.InsecureNoAuth {
+		return nil
+	}
+
+	if ip := net.ParseIP(cfg.HttpHost); cfg.HttpHost == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+
+	return errors.New("api_keys is empty, which leaves the control api open: add api keys, set http_host to 127.0.0.1 or set insecure_no_auth")
 }
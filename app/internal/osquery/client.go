@@ -0,0 +1,239 @@
+// Package osquery runs osquery SQL, over the extension socket of a running osqueryd
+// when there is one and with an osqueryi process otherwise.
+package osquery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// callTimeout - how long a query over the socket may take before the connection is dropped
+const callTimeout = 30 * time.Second
+
+// dialTimeout - how long connecting to the socket may take
+const dialTimeout = 2 * time.Second
+
+var ErrParameters = errors.New("osquery: the number of parameters does not match the placeholders")
+
+// errUnavailable - the socket could not be connected to, the query falls back to osqueryi
+var errUnavailable = errors.New("osquery: extension socket unavailable")
+
+// Client - runs queries over one reused extension socket connection,
+// with an osqueryi process when no socket is configured or it cannot be reached
+type Client struct {
+	socket string
+	dial   func(socket string, timeout time.Duration) (net.Conn, error)
+
+	lock chan struct{}
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	seq  int32
+}
+
+// NewClient - client for the extension socket, an empty socket always runs osqueryi
+func NewClient(socket string) *Client {
+	return &Client{socket: socket, dial: dial, lock: make(chan struct{}, 1)}
+}
+
+// Query - run sql with its ? placeholders bound to args as quoted string literals,
+// returns the rows as column name to value. A nil client runs osqueryi.
+// Cancelling ctx, or its deadline, ends the wait for the connection, the call over it or the osqueryi process.
+func (c *Client) Query(ctx context.Context, sql string, args ...string) ([]map[string]string, error) {
+	bound, err := Bind(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c == nil || c.socket == "" {
+		return queryProcess(ctx, bound)
+	}
+
+	// one call at a time over the connection, waiting for it can be cancelled
+	select {
+	case c.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.lock }()
+
+	rows, err := c.querySocket(ctx, bound)
+	if errors.Is(err, errUnavailable) {
+		return queryProcess(ctx, bound)
+	}
+
+	return rows, err
+}
+
+// QueryInto - Query decoding the rows into dst, a pointer to a slice of structs with json tags
+func (c *Client) QueryInto(ctx context.Context, dst interface{}, sql string, args ...string) error {
+	rows, err := c.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(js, dst)
+}
+
+// Close - close the socket connection
+func (c *Client) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.lock <- struct{}{}
+	defer func() { <-c.lock }()
+
+	return c.disconnect()
+}
+
+// querySocket - run the query over the connection, reconnecting once when a kept connection has gone stale
+func (c *Client) querySocket(ctx context.Context, sql string) ([]map[string]string, error) {
+	for attempt := 0; ; attempt++ {
+		reused := c.conn != nil
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.call(ctx, sql)
+		if err != nil {
+			// the connection state is unknown after a failed call
+			c.disconnect()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if reused && attempt == 0 {
+				continue
+			}
+			return nil, err
+		}
+
+		if resp.code != 0 {
+			return nil, fmt.Errorf("osquery: %s", resp.message)
+		}
+		return resp.rows, nil
+	}
+}
+
+// call - one query call and its reply, ended by the call timeout, the deadline of ctx or its cancellation
+func (c *Client) call(ctx context.Context, sql string) (*extensionResponse, error) {
+	// a hung osqueryd must not keep the client locked
+	deadline := time.Now().Add(callTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn := c.conn
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c.seq++
+	if err := writeQueryCall(c.w, c.seq, sql); err != nil {
+		return nil, err
+	}
+
+	return readQueryReply(c.r, c.seq)
+}
+
+func (c *Client) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := c.dial(c.socket, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnavailable, err)
+	}
+
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+
+	return nil
+}
+
+func (c *Client) disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn, c.r, c.w = nil, nil, nil
+
+	return err
+}
+
+// queryProcess - run the query with osqueryi, which prints the rows as json, the process is killed after the call timeout
+func queryProcess(ctx context.Context, sql string) ([]map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "osqueryi", "--json", sql).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	var rows []map[string]string
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Bind - replace the ? placeholders of sql outside string literals with the args quoted
+func Bind(sql string, args ...string) (string, error) {
+	var b strings.Builder
+	inString := false
+	next := 0
+	for _, r := range sql {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			if next == len(args) {
+				return "", ErrParameters
+			}
+			literal, err := Quote(args[next])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(literal)
+			next++
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	if next != len(args) {
+		return "", ErrParameters
+	}
+
+	return b.String(), nil
+}
+
+// Quote - s as an SQL string literal, quotes are doubled so the value cannot end the literal
+func Quote(s string) (string, error) {
+	if strings.ContainsRune(s, 0) {
+		return "", errors.New("osquery: parameter contains a NUL byte")
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+}
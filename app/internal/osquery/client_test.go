@@ -0,0 +1,216 @@
+package osquery
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		args []string
+		want string
+		err  bool
+	}{
+		{"plain", "SELECT * FROM file WHERE path = ?;", []string{"/tmp/a.txt"}, "SELECT * FROM file WHERE path = '/tmp/a.txt';", false},
+		{"quote", "SELECT * FROM file WHERE path = ?;", []string{"/tmp/a' OR '1'='1"}, "SELECT * FROM file WHERE path = '/tmp/a'' OR ''1''=''1';", false},
+		{"placeholder in literal", "SELECT * FROM file WHERE path LIKE '?%' AND directory = ?;", []string{"/tmp"}, "SELECT * FROM file WHERE path LIKE '?%' AND directory = '/tmp';", false},
+		{"too few", "SELECT * FROM file WHERE path = ? AND directory = ?;", []string{"/tmp"}, "", true},
+		{"too many", "SELECT * FROM file;", []string{"/tmp"}, "", true},
+		{"nul byte", "SELECT * FROM file WHERE path = ?;", []string{"/tmp/a\x00"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Bind(tt.sql, tt.args...)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// fakeManager - answers query calls like the ExtensionManager of osqueryd, reply returns the status and rows for a query
+func fakeManager(t *testing.T, conn net.Conn, reply func(sql string) (int32, []map[string]string)) {
+	t.Helper()
+
+	go func() {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		w := bufio.NewWriter(conn)
+		for {
+			// call header, then query_args {1: string sql}
+			if _, err := readI32(r); err != nil {
+				return
+			}
+			if name, err := readString(r); err != nil || name != "query" {
+				return
+			}
+			seq, err := readI32(r)
+			if err != nil {
+				return
+			}
+			var sql string
+			if err := readStruct(r, func(id int16, fieldType byte) (bool, error) {
+				sql, err = readString(r)
+				return true, err
+			}); err != nil {
+				return
+			}
+
+			code, rows := reply(sql)
+
+			var header uint32 = version1 | messageReply
+			writeI32(w, int32(header))
+			writeString(w, "query")
+			writeI32(w, seq)
+
+			// query_result {0: ExtensionResponse {1: ExtensionStatus, 2: rows}}
+			w.WriteByte(typeStruct)
+			writeI16(w, 0)
+			w.WriteByte(typeStruct)
+			writeI16(w, 1)
+			w.WriteByte(typeI32)
+			writeI16(w, 1)
+			writeI32(w, code)
+			w.WriteByte(typeString)
+			writeI16(w, 2)
+			writeString(w, "some message")
+			w.WriteByte(typeI64)
+			writeI16(w, 3)
+			w.Write(make([]byte, 8))
+			w.WriteByte(typeStop)
+			w.WriteByte(typeList)
+			writeI16(w, 2)
+			w.WriteByte(typeMap)
+			writeI32(w, int32(len(rows)))
+			for _, row := range rows {
+				w.WriteByte(typeString)
+				w.WriteByte(typeString)
+				writeI32(w, int32(len(row)))
+				for k, v := range row {
+					writeString(w, k)
+					writeString(w, v)
+				}
+			}
+			w.WriteByte(typeStop)
+			w.WriteByte(typeStop)
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestClientSocket(t *testing.T) {
+	var queries []string
+	dials := 0
+
+	c := NewClient("test.em")
+	c.dial = func(socket string, timeout time.Duration) (net.Conn, error) {
+		dials++
+		client, server := net.Pipe()
+		fakeManager(t, server, func(sql string) (int32, []map[string]string) {
+			queries = append(queries, sql)
+			if sql == "SELECT broken;" {
+				return 1, nil
+			}
+			return 0, []map[string]string{{"path": "/tmp/it's.txt", "type": "regular"}}
+		})
+		return client, nil
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		rows, err := c.Query(ctx, "SELECT path, type FROM file WHERE path = ?;", "/tmp/it's.txt")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		want := []map[string]string{{"path": "/tmp/it's.txt", "type": "regular"}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("Expected rows %v, got %v", want, rows)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("Expected the connection to be reused, dialed %d times", dials)
+	}
+	if queries[0] != "SELECT path, type FROM file WHERE path = '/tmp/it''s.txt';" {
+		t.Errorf("Expected the path to be quoted, got %q", queries[0])
+	}
+
+	if _, err := c.Query(ctx, "SELECT broken;"); err == nil {
+		t.Error("Expected an error for a failed query status")
+	}
+
+	var files []struct {
+		Path string `json:"path"`
+	}
+	if err := c.QueryInto(ctx, &files, "SELECT path FROM file WHERE path = ?;", "/tmp/a.txt"); err != nil || len(files) != 1 || files[0].Path != "/tmp/it's.txt" {
+		t.Errorf("Expected the rows decoded, got %v (%v)", files, err)
+	}
+
+	// a dropped connection is dialed again
+	c.conn.Close()
+	if _, err := c.Query(ctx, "SELECT 1;"); err != nil {
+		t.Fatalf("Failed to query after the connection dropped: %v", err)
+	}
+	if dials != 2 {
+		t.Errorf("Expected a reconnect, dialed %d times", dials)
+	}
+}
+
+func TestClientUnavailable(t *testing.T) {
+	c := NewClient("missing.em")
+	c.dial = func(socket string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("no such file")
+	}
+
+	_, err := c.querySocket(context.Background(), "SELECT 1;")
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("Expected the socket to be reported unavailable for the osqueryi fallback, got %v", err)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	c := NewClient("hung.em")
+	c.dial = func(socket string, timeout time.Duration) (net.Conn, error) {
+		// osqueryd accepts the call but never replies
+		client, server := net.Pipe()
+		go func() {
+			io.Copy(io.Discard, server)
+		}()
+		return client, nil
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.Query(ctx, "SELECT 1;"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the call to end with the context, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the call to end at the context deadline, took %s", time.Since(start))
+	}
+
+	// a call waiting for the connection is cancelled too
+	c.lock <- struct{}{}
+	waiting, stop := context.WithCancel(context.Background())
+	stop()
+	if _, err := c.Query(waiting, "SELECT 1;"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait for the connection to be cancelled, got %v", err)
+	}
+	<-c.lock
+}
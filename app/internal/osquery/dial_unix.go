@@ -0,0 +1,13 @@
+//go:build !windows
+
+package osquery
+
+import (
+	"net"
+	"time"
+)
+
+// dial - connect to the unix domain socket of osqueryd
+func dial(socket string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", socket, timeout)
+}
@@ -0,0 +1,13 @@
+package osquery
+
+import (
+	"net"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// dial - connect to the named pipe of osqueryd, e.g. \\.\pipe\shell.em, the connection supports deadlines
+func dial(socket string, timeout time.Duration) (net.Conn, error) {
+	return winio.DialPipe(socket, &timeout)
+}
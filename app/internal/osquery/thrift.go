@@ -0,0 +1,371 @@
+package osquery
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// thrift binary protocol, just enough of it for the ExtensionManager.query call of osquery.thrift
+
+// message types
+const (
+	messageCall      = 1
+	messageReply     = 2
+	messageException = 3
+)
+
+// strict binary protocol version, or-ed with the message type
+const (
+	versionMask = 0xffff0000
+	version1    = 0x80010000
+)
+
+// field types
+const (
+	typeStop   = 0
+	typeBool   = 2
+	typeByte   = 3
+	typeDouble = 4
+	typeI16    = 6
+	typeI32    = 8
+	typeI64    = 10
+	typeString = 11
+	typeStruct = 12
+	typeMap    = 13
+	typeSet    = 14
+	typeList   = 15
+)
+
+// maxStringSize - largest string accepted from the socket, guards against a corrupt length
+const maxStringSize = 64 << 20
+
+// maxSkipDepth - deepest nesting skipped in a field this client does not read
+const maxSkipDepth = 64
+
+var errProtocol = errors.New("osquery: malformed thrift response")
+
+// extensionResponse - ExtensionResponse of osquery.thrift
+type extensionResponse struct {
+	code    int32
+	message string
+	rows    []map[string]string
+}
+
+// writeQueryCall - write the call of ExtensionManager.query(1: string sql)
+func writeQueryCall(w *bufio.Writer, seq int32, sql string) error {
+	var header uint32 = version1 | messageCall
+	writeI32(w, int32(header))
+	writeString(w, "query")
+	writeI32(w, seq)
+
+	// query_args struct
+	w.WriteByte(typeString)
+	writeI16(w, 1)
+	writeString(w, sql)
+	w.WriteByte(typeStop)
+
+	return w.Flush()
+}
+
+// readQueryReply - read the reply of ExtensionManager.query, an exception is returned as an error
+func readQueryReply(r *bufio.Reader, seq int32) (*extensionResponse, error) {
+	header, err := readI32(r)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(header)&versionMask != version1 {
+		return nil, fmt.Errorf("osquery: unsupported thrift protocol version %#x", uint32(header)&versionMask)
+	}
+
+	if _, err := readString(r); err != nil {
+		return nil, err
+	}
+	replySeq, err := readI32(r)
+	if err != nil {
+		return nil, err
+	}
+	if replySeq != seq {
+		return nil, fmt.Errorf("osquery: reply to call %d, expected %d", replySeq, seq)
+	}
+
+	switch header & 0xff {
+	case messageReply:
+	case messageException:
+		// TApplicationException {1: string message, 2: i32 type}
+		var message string
+		err := readStruct(r, func(id int16, fieldType byte) (bool, error) {
+			if id == 1 && fieldType == typeString {
+				message, err = readString(r)
+				return true, err
+			}
+			return false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("osquery: %s", message)
+	default:
+		return nil, errProtocol
+	}
+
+	// query_result {0: ExtensionResponse success}
+	var resp *extensionResponse
+	err = readStruct(r, func(id int16, fieldType byte) (bool, error) {
+		if id == 0 && fieldType == typeStruct {
+			resp, err = readExtensionResponse(r)
+			return true, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errProtocol
+	}
+
+	return resp, nil
+}
+
+// readExtensionResponse - ExtensionResponse {1: ExtensionStatus status, 2: list<map<string,string>> response}
+func readExtensionResponse(r *bufio.Reader) (*extensionResponse, error) {
+	resp := &extensionResponse{}
+	err := readStruct(r, func(id int16, fieldType byte) (bool, error) {
+		switch {
+		case id == 1 && fieldType == typeStruct:
+			// ExtensionStatus {1: i32 code, 2: string message, 3: i64 uuid}
+			return true, readStruct(r, func(id int16, fieldType byte) (bool, error) {
+				var err error
+				switch {
+				case id == 1 && fieldType == typeI32:
+					resp.code, err = readI32(r)
+					return true, err
+				case id == 2 && fieldType == typeString:
+					resp.message, err = readString(r)
+					return true, err
+				}
+				return false, nil
+			})
+		case id == 2 && fieldType == typeList:
+			rows, err := readRows(r)
+			resp.rows = rows
+			return true, err
+		}
+		return false, nil
+	})
+
+	return resp, err
+}
+
+// readRows - list<map<string,string>>
+func readRows(r *bufio.Reader) ([]map[string]string, error) {
+	elemType, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	size, err := readSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if elemType != typeMap {
+		return nil, errProtocol
+	}
+
+	// the size is not trusted for the allocation, a corrupt one fails on the reads instead
+	rows := make([]map[string]string, 0, min(size, 1024))
+	for i := 0; i < size; i++ {
+		keyType, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		valueType, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		columns, err := readSize(r)
+		if err != nil {
+			return nil, err
+		}
+		if keyType != typeString || valueType != typeString {
+			return nil, errProtocol
+		}
+
+		row := make(map[string]string, min(columns, 64))
+		for j := 0; j < columns; j++ {
+			key, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readString(r)
+			if err != nil {
+				return nil, err
+			}
+			row[key] = value
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readStruct - read the fields of a struct up to its stop field,
+// fn reads the fields it knows and returns false for the ones to skip
+func readStruct(r *bufio.Reader, fn func(id int16, fieldType byte) (bool, error)) error {
+	for {
+		fieldType, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if fieldType == typeStop {
+			return nil
+		}
+
+		id, err := readI16(r)
+		if err != nil {
+			return err
+		}
+
+		read, err := fn(id, fieldType)
+		if err != nil {
+			return err
+		}
+		if !read {
+			if err := skip(r, fieldType, 0); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// skip - read past a value of the type
+func skip(r *bufio.Reader, fieldType byte, depth int) error {
+	if depth > maxSkipDepth {
+		return errProtocol
+	}
+
+	var n int
+	switch fieldType {
+	case typeBool, typeByte:
+		n = 1
+	case typeI16:
+		n = 2
+	case typeI32:
+		n = 4
+	case typeDouble, typeI64:
+		n = 8
+	case typeString:
+		_, err := readString(r)
+		return err
+	case typeStruct:
+		return readStruct(r, func(id int16, fieldType byte) (bool, error) {
+			return true, skip(r, fieldType, depth+1)
+		})
+	case typeMap:
+		keyType, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		valueType, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		size, err := readSize(r)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, keyType, depth+1); err != nil {
+				return err
+			}
+			if err := skip(r, valueType, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case typeSet, typeList:
+		elemType, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		size, err := readSize(r)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, elemType, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errProtocol
+	}
+
+	_, err := r.Discard(n)
+	return err
+}
+
+func writeI16(w *bufio.Writer, v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.Write(b[:])
+}
+
+func writeI32(w *bufio.Writer, v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.Write(b[:])
+}
+
+func writeString(w *bufio.Writer, s string) {
+	writeI32(w, int32(len(s)))
+	w.WriteString(s)
+}
+
+func readI16(r *bufio.Reader) (int16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+
+	return int16(binary.BigEndian.Uint16(b[:])), nil
+}
+
+func readI32(r *bufio.Reader) (int32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+
+	return int32(binary.BigEndian.Uint32(b[:])), nil
+}
+
+// readSize - a non-negative container size or string length
+func readSize(r *bufio.Reader) (int, error) {
+	size, err := readI32(r)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || size > maxStringSize {
+		return 0, errProtocol
+	}
+
+	return int(size), nil
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	size, err := readSize(r)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// access error codes, sent with the error response so clients can tell the denials apart
+const (
+	CodeInvalidPath   = "invalid_path"
+	CodePathNotFound  = "path_not_found"
+	CodeOutsideRoots  = "outside_allowed_roots"
+	CodeCommandDenied = "command_not_allowed"
+)
+
+// AccessError - a path a command may not run on
+type AccessError struct {
+	Code    string
+	Path    string
+	Message string
+}
+
+func (e *AccessError) Error() string {
+	return fmt.Sprintf("invalid path: %s", e.Message)
+}
+
+// CheckAccess - check that path is absolute, exists and resolves inside an allowed root that permits the command,
+// returning the resolved path. Symlinks and .. are resolved first, so neither can be used to reach a path outside the roots,
+// and the command runs on the resolved path so a symlink swapped in after the check is not followed.
+func (cf *CommandFileInfo) CheckAccess(command, path string) (string, error) {
+	// absolute path check
+	if !filepath.IsAbs(path) {
+		return "", &AccessError{Code: CodeInvalidPath, Path: path, Message: "path must be absolute"}
+	}
+
+	// check if the path contains any wildcards or patterns
+	if strings.ContainsAny(path, "*?[]") {
+		return "", &AccessError{Code: CodeInvalidPath, Path: path, Message: "path must not contain wildcards or patterns"}
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// only tell whether the path exists when it would be inside a root
+		if _, ok := cf.rootOf(filepath.Clean(path), false); !ok {
+			return "", &AccessError{Code: CodeOutsideRoots, Path: path, Message: "path is outside the allowed roots"}
+		}
+		return "", &AccessError{Code: CodePathNotFound, Path: path, Message: "path does not exist"}
+	}
+
+	root, ok := cf.rootOf(resolved, true)
+	if !ok {
+		return "", &AccessError{Code: CodeOutsideRoots, Path: path, Message: "path is outside the allowed roots"}
+	}
+
+	if len(root.Commands) > 0 && !contains(root.Commands, command) {
+		return "", &AccessError{Code: CodeCommandDenied, Path: path, Message: fmt.Sprintf("%s is not allowed under %s", command, root.Path)}
+	}
+
+	return resolved, nil
+}
+
+// CheckRoots - reject allowed roots listing a command that is not registered, a misspelt name would deny the
+// command under the root without any error
+func (r *Registry) CheckRoots(roots []config.AllowedRoot) error {
+	for _, root := range roots {
+		for _, name := range root.Commands {
+			if _, ok := r.Lookup(name); !ok {
+				return fmt.Errorf("allowed_roots: %s lists the unknown command %q", root.Path, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rootOf - the deepest allowed root containing path, with resolve the roots have their symlinks resolved too
+func (cf *CommandFileInfo) rootOf(path string, resolve bool) (config.AllowedRoot, bool) {
+	var found config.AllowedRoot
+	depth := -1
+	for _, root := range cf.roots {
+		rootPath := filepath.Clean(root.Path)
+		if resolve {
+			resolved, err := filepath.EvalSymlinks(rootPath)
+			if err != nil {
+				continue
+			}
+			rootPath = resolved
+		}
+
+		if within(rootPath, path) && len(rootPath) > depth {
+			found, depth = root, len(rootPath)
+		}
+	}
+
+	return found, depth >= 0
+}
+
+// within - whether path is root or below it, comparing whole path elements
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) && !filepath.IsAbs(rel)
+}
+
+// contains - whether the list has the command, ignoring case
+func contains(list []string, command string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, command) {
+			return true
+		}
+	}
+
+	return false
+}
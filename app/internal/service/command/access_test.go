@@ -0,0 +1,97 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+func TestCheckAccess(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Desktop/a.txt", "Desktop/reports/b.txt", "Desktop-evil/c.txt", "secret.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	desktop := filepath.Join(dir, "Desktop")
+	escape := filepath.Join(desktop, "escape.txt")
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), escape); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	link := filepath.Join(desktop, "link.txt")
+	if err := os.Symlink(filepath.Join(desktop, "a.txt"), link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	target, err := filepath.EvalSymlinks(filepath.Join(desktop, "a.txt"))
+	if err != nil {
+		t.Fatalf("Failed to resolve test file: %v", err)
+	}
+
+	cf := &CommandFileInfo{roots: []config.AllowedRoot{
+		{Path: desktop},
+		{Path: filepath.Join(desktop, "reports"), Commands: []string{"CHECK_FILE_DATES"}},
+	}}
+
+	tests := []struct {
+		name    string
+		command string
+		path    string
+		code    string
+	}{
+		{"inside root", "GET_FILE_INFO", filepath.Join(desktop, "a.txt"), ""},
+		{"relative", "GET_FILE_INFO", "Desktop/a.txt", CodeInvalidPath},
+		{"wildcard", "GET_FILE_INFO", filepath.Join(desktop, "*.txt"), CodeInvalidPath},
+		{"missing", "GET_FILE_INFO", filepath.Join(desktop, "missing.txt"), CodePathNotFound},
+		{"missing outside", "GET_FILE_INFO", filepath.Join(dir, "missing.txt"), CodeOutsideRoots},
+		{"dot dot", "GET_FILE_INFO", desktop + string(os.PathSeparator) + ".." + string(os.PathSeparator) + "secret.txt", CodeOutsideRoots},
+		{"sibling prefix", "GET_FILE_INFO", filepath.Join(dir, "Desktop-evil", "c.txt"), CodeOutsideRoots},
+		{"symlink escape", "GET_FILE_INFO", escape, CodeOutsideRoots},
+		{"symlink inside root", "GET_FILE_INFO", link, ""},
+		{"root command allowed", "CHECK_FILE_DATES", filepath.Join(desktop, "reports", "b.txt"), ""},
+		{"root command denied", "GET_FILE_INFO", filepath.Join(desktop, "reports", "b.txt"), CodeCommandDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := cf.CheckAccess(tt.command, tt.path)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Expected access to %s, got %v", tt.path, err)
+				}
+				if tt.path == link && resolved != target {
+					t.Errorf("Expected the symlink to resolve to %s, got %s", target, resolved)
+				}
+				return
+			}
+
+			var accessErr *AccessError
+			if !errors.As(err, &accessErr) {
+				t.Fatalf("Expected an access error, got %v", err)
+			}
+			if accessErr.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, accessErr.Code)
+			}
+		})
+	}
+}
+
+func TestCheckRoots(t *testing.T) {
+	roots := []config.AllowedRoot{{Path: "/data"}, {Path: "/var/log", Commands: []string{"CHECK_FILE_DATES", "check_if_modified_file"}}}
+	if err := Default().CheckRoots(roots); err != nil {
+		t.Errorf("Expected the registered commands to pass, got %v", err)
+	}
+
+	roots[1].Commands = append(roots[1].Commands, "CHECK_FILE_MODIFIED")
+	if err := Default().CheckRoots(roots); err == nil || !strings.Contains(err.Error(), "CHECK_FILE_MODIFIED") {
+		t.Errorf("Expected the unknown command to be named in an error, got %v", err)
+	}
+}
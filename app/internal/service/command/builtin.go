@@ -0,0 +1,111 @@
+package command
+
+import (
+	"context"
+)
+
+// pathParam - the file a built-in command runs on
+var pathParam = Param{Name: "path", Type: ParamPath, Required: true, Description: "Absolute path of the file, inside an allowed root"}
+
+// dirParam, sortParam and orderParam - the directory LIST_DIRECTORY and TREE run on and the order of its entries
+var (
+	dirParam   = Param{Name: "path", Type: ParamPath, Required: true, Description: "Absolute path of the directory, inside an allowed root"}
+	sortParam  = Param{Name: "sort", Type: ParamString, Default: SortName, Values: []string{SortName, SortSize, SortMtime}, Description: "Sort the entries by name, size or modified time"}
+	orderParam = Param{Name: "order", Type: ParamString, Default: OrderAsc, Values: []string{OrderAsc, OrderDesc}, Description: "Ascending or descending order"}
+)
+
+// built-in commands
+func init() {
+	Register(Command{
+		Name:        "CHECK_DIRECTORY_FILE",
+		Description: "Checks file information for a given file path",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFileInfo(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "CHECK_FILE_PERMISSION",
+		Description: "Checks file permission of a given file",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFilePermissions(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "CHECK_FILE_TYPE",
+		Description: "Checks file type for a given path",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFileType(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "CHECK_IS_FILE_TYPE",
+		Description: "Checks documents for a given path",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchIsFile(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "CHECK_FILE_DATES",
+		Description: "Checks file times",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFileDate(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "CHECK_IF_MODIFIED_FILE",
+		Description: "Checks file modified for a given path",
+		Params:      []Param{pathParam},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFileIsModified(ctx, args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "FILE_HISTORY",
+		Description: "Lists every recorded state of a file, also after it was deleted, with the changes from the state before",
+		Params:      []Param{{Name: "path", Type: ParamString, Required: true, Description: "Absolute path of the file, inside an allowed root, it may no longer exist"}},
+		Usage:       "/execute?command=FILE_HISTORY&path=/path/to/file",
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.FetchFileHistory(args.String("path"))
+		},
+	})
+	Register(Command{
+		Name:        "LIST_DIRECTORY",
+		Description: "Lists a page of the children of a directory with their file information and the totals of all of them",
+		Params: []Param{
+			dirParam, sortParam, orderParam,
+			{Name: "offset", Type: ParamInt, Default: "0", Description: "Number of entries to skip, the next of the previous page"},
+			{Name: "limit", Type: ParamInt, Default: "100", Min: 1, Max: 1000, Description: "Most entries returned"},
+		},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.ListDirectory(ctx, args.String("path"), args.String("sort"), args.String("order"), args.Int("offset"), args.Int("limit"))
+		},
+	})
+	Register(Command{
+		Name:        "TREE",
+		Description: "Walks a directory up to depth levels, returning its entries with the recursive file count and size of every directory",
+		Params: []Param{
+			dirParam, sortParam, orderParam,
+			{Name: "depth", Type: ParamInt, Default: "3", Min: 1, Max: 10, Description: "Levels below the directory to walk"},
+		},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.Tree(ctx, args.String("path"), args.String("sort"), args.String("order"), args.Int("depth"))
+		},
+	})
+	Register(Command{
+		Name:        "OSQUERY",
+		Description: "Runs a read-only SELECT on the allowed osquery tables, returning at most limit rows",
+		Usage:       "/execute?command=OSQUERY&sql=SELECT path, size FROM file WHERE directory = '/path/to/dir'&limit=100",
+		Params: []Param{
+			{Name: "sql", Type: ParamString, Required: true, Description: "A single SELECT on the tables of osquery_tables"},
+			{Name: "limit", Type: ParamInt, Min: 1, Description: "Most rows returned, at most osquery_row_limit which is also the default"},
+		},
+		Handler: func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+			return cf.RunQuery(ctx, args.String("sql"), args.Int("limit"))
+		},
+	})
+}
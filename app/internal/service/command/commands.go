@@ -1,14 +1,14 @@
 package command
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
 	"github.com/thespider911/filetrackermodification/app/internal/helpers"
-	"os"
-	"os/exec"
+	"github.com/thespider911/filetrackermodification/app/internal/osquery"
 	"path/filepath"
-	"strings"
+	"time"
 )
 
 var ErrNoFile = errors.New("models: no such existing file record found")
@@ -57,71 +57,63 @@ type FileModified struct {
 	CTimeDiff string `json:"changed_time_diff"`
 }
 
-// CommandRunFile -
+// CommandRunFile - runs the registered commands
 type CommandRunFile interface {
-	ExecuteCommand(string, map[string]string) (interface{}, error)
-	FetchFileInfo(string) (*FileInfo, error)
-	FetchFilePermissions(string) (*PermissionModeInfos, error)
-	FetchFileType(string) (*FileTypeInfos, error)
-	FetchIsFile(string) (bool, error)
-	FetchFileDate(string) (*FileDates, error)
-	FetchFileIsModified(string) (*FileModified, error)
+	ExecuteCommand(context.Context, string, map[string]string) (interface{}, error)
+	Prepare(string, map[string]string) (Command, Args, error)
+	Lookup(string) (Command, bool)
+	Commands() []Command
 }
 
-type CommandFileInfo struct{}
-
-// NewCommandFileInfo - new instance of CommandFileInfo
-func NewCommandFileInfo() CommandRunFile {
-	return &CommandFileInfo{}
+// CommandFileInfo - runs the commands on paths inside the allowed roots and queries on the allowed tables
+type CommandFileInfo struct {
+	roots        []config.AllowedRoot
+	tables       []string
+	rowLimit     int
+	queryTimeout time.Duration
+	osquery      *osquery.Client
+	history      History
+	registry     *Registry
 }
 
-// validatePath - check if the given path is valid, specific, exists, and is within the Desktop directory
-func (cf *CommandFileInfo) validatePath(path string) error {
-	// absolute path check
-	if !filepath.IsAbs(path) {
-		return fmt.Errorf("path must be absolute")
-	}
-
-	// check if the path contains any wildcards or patterns
-	if strings.ContainsAny(path, "*?[]") {
-		return fmt.Errorf("path must not contain wildcards or patterns")
-	}
-
-	// check if the path exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("path does not exist")
+// NewCommandFileInfo - new instance of CommandFileInfo running the commands of the default registry,
+// limited by the config, querying with the osquery client and reading the recorded scans from history
+func NewCommandFileInfo(cfg config.Config, client *osquery.Client, history History) *CommandFileInfo {
+	return &CommandFileInfo{
+		roots:        cfg.AllowedRoots,
+		tables:       cfg.OsqueryTables,
+		rowLimit:     cfg.OsqueryRowLimit,
+		queryTimeout: time.Duration(cfg.OsqueryTimeout) * time.Second,
+		osquery:      client,
+		history:      history,
+		registry:     Default(),
 	}
+}
 
-	// check if the path is within the Desktop directory
-	desktopPath, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("unable to determine user's home directory: %v", err)
-	}
-	desktopPath = filepath.Join(desktopPath, "Desktop")
+// Osquery - the osquery client, for registered commands that run their own queries
+func (cf *CommandFileInfo) Osquery() *osquery.Client {
+	return cf.osquery
+}
 
-	if !strings.HasPrefix(path, desktopPath) {
-		return fmt.Errorf("path must be within the Desktop directory")
-	}
+// Lookup - the registered command by name, ignoring case
+func (cf *CommandFileInfo) Lookup(name string) (Command, bool) {
+	return cf.registry.Lookup(name)
+}
 
-	return nil
+// Commands - every registered command, sorted by name
+func (cf *CommandFileInfo) Commands() []Command {
+	return cf.registry.Commands()
 }
 
 // --------------- COMMANDS --------------- //
 
 // FetchFileInfo - get file info from querying the path returning fileInfo
-func (cf *CommandFileInfo) FetchFileInfo(filePath string) (*FileInfo, error) {
-	// osquery query and command run
-	query := fmt.Sprintf("SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode FROM file WHERE path = '%s';", filepath.Clean(filePath))
-	cmd := exec.Command("osqueryi", "--json", query)
+func (cf *CommandFileInfo) FetchFileInfo(ctx context.Context, filePath string) (*FileInfo, error) {
+	query := "SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode FROM file WHERE path = ?;"
 
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// decode the output
+	// run the query and decode the rows
 	var fileInfos []FileInfo
-	if err := json.Unmarshal(output, &fileInfos); err != nil {
+	if err := cf.osquery.QueryInto(ctx, &fileInfos, query, filepath.Clean(filePath)); err != nil {
 		return nil, err
 	}
 
@@ -141,27 +133,18 @@ func (cf *CommandFileInfo) FetchFileInfo(filePath string) (*FileInfo, error) {
 	}
 
 	//format time and size values
-	fileInfo.Mtime = helpers.ToHumanReadableTime(fileInfo.Mtime)
-	fileInfo.ATime = helpers.ToHumanReadableTime(fileInfo.ATime)
-	fileInfo.CTime = helpers.ToHumanReadableTimeDiff(fileInfo.CTime)
-	fileInfo.Size = helpers.ToHumanReadableFileSize(fileInfo.Size)
+	formatFileInfo(fileInfo)
 
 	return fileInfo, nil
 }
 
 // FetchFilePermissions - get file permissions
-func (cf *CommandFileInfo) FetchFilePermissions(filePath string) (*PermissionModeInfos, error) {
-	query := fmt.Sprintf("SELECT path, mode, type FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
+func (cf *CommandFileInfo) FetchFilePermissions(ctx context.Context, filePath string) (*PermissionModeInfos, error) {
+	query := "SELECT path, mode, type FROM file WHERE path = ?;"
 
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// decode the output
+	// run the query and decode the rows
 	var modePermInfos []PermissionModeInfos
-	err = json.Unmarshal(output, &modePermInfos)
+	err := cf.osquery.QueryInto(ctx, &modePermInfos, query, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -184,18 +167,12 @@ func (cf *CommandFileInfo) FetchFilePermissions(filePath string) (*PermissionMod
 }
 
 // FetchFileType - get file types
-func (cf *CommandFileInfo) FetchFileType(filePath string) (*FileTypeInfos, error) {
-	query := fmt.Sprintf("SELECT path, filename, type FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+func (cf *CommandFileInfo) FetchFileType(ctx context.Context, filePath string) (*FileTypeInfos, error) {
+	query := "SELECT path, filename, type FROM file WHERE path = ?;"
 
-	// decode the output
+	// run the query and decode the rows
 	var fileInfos []FileTypeInfos
-	err = json.Unmarshal(output, &fileInfos)
+	err := cf.osquery.QueryInto(ctx, &fileInfos, query, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -209,18 +186,12 @@ func (cf *CommandFileInfo) FetchFileType(filePath string) (*FileTypeInfos, error
 }
 
 // FetchIsFile - check file directory
-func (cf *CommandFileInfo) FetchIsFile(filePath string) (bool, error) {
-	query := fmt.Sprintf("SELECT path, filename, type FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
-	}
+func (cf *CommandFileInfo) FetchIsFile(ctx context.Context, filePath string) (bool, error) {
+	query := "SELECT path, filename, type FROM file WHERE path = ?;"
 
-	// decode the output
+	// run the query and decode the rows
 	var fileInfos []FileTypeInfos
-	err = json.Unmarshal(output, &fileInfos)
+	err := cf.osquery.QueryInto(ctx, &fileInfos, query, filePath)
 	if err != nil {
 		return false, err
 	}
@@ -243,16 +214,10 @@ func (cf *CommandFileInfo) FetchIsFile(filePath string) (bool, error) {
 }
 
 // FetchFileDate - get file date
-func (cf *CommandFileInfo) FetchFileDate(filePath string) (*FileDates, error) {
-	query := fmt.Sprintf("SELECT path, filename, mtime, atime, ctime, type  FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
+func (cf *CommandFileInfo) FetchFileDate(ctx context.Context, filePath string) (*FileDates, error) {
+	query := "SELECT path, filename, mtime, atime, ctime, type  FROM file WHERE path = ?;"
 
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// decode the output
+	// run the query and decode the rows
 	var fileInfos []struct {
 		Path     string `json:"path"`
 		Filename string `json:"filename"`
@@ -261,7 +226,7 @@ func (cf *CommandFileInfo) FetchFileDate(filePath string) (*FileDates, error) {
 		CTime    string `json:"ctime"`
 		Type     string `json:"type"`
 	}
-	err = json.Unmarshal(output, &fileInfos)
+	err := cf.osquery.QueryInto(ctx, &fileInfos, query, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -294,23 +259,17 @@ func (cf *CommandFileInfo) FetchFileDate(filePath string) (*FileDates, error) {
 }
 
 // FetchFileIsModified - get file date
-func (cf *CommandFileInfo) FetchFileIsModified(filePath string) (*FileModified, error) {
-	query := fmt.Sprintf("SELECT path, filename, ctime, type  FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+func (cf *CommandFileInfo) FetchFileIsModified(ctx context.Context, filePath string) (*FileModified, error) {
+	query := "SELECT path, filename, ctime, type  FROM file WHERE path = ?;"
 
-	// decode the output
+	// run the query and decode the rows
 	var fileInfos []struct {
 		Path     string `json:"path"`
 		Filename string `json:"filename"`
 		CTime    string `json:"ctime"`
 		Type     string `json:"type"`
 	}
-	err = json.Unmarshal(output, &fileInfos)
+	err := cf.osquery.QueryInto(ctx, &fileInfos, query, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -338,32 +297,38 @@ func (cf *CommandFileInfo) FetchFileIsModified(filePath string) (*FileModified,
 	return nil, errors.New("no result found")
 }
 
-// ExecuteCommand -  executes a given command
-func (cf *CommandFileInfo) ExecuteCommand(command string, params map[string]string) (interface{}, error) {
-	path, ok := params["path"]
+// ExecuteCommand - validate the parameters of a registered command, check its paths against the allowed roots and run it
+func (cf *CommandFileInfo) ExecuteCommand(ctx context.Context, name string, params map[string]string) (interface{}, error) {
+	command, args, err := cf.Prepare(name, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return command.Handler(ctx, cf, args)
+}
+
+// Prepare - the registered command with its validated arguments, every path parameter checked against the allowed roots
+func (cf *CommandFileInfo) Prepare(name string, params map[string]string) (Command, Args, error) {
+	command, ok := cf.registry.Lookup(name)
 	if !ok {
-		return nil, fmt.Errorf("%s requires a 'path' parameter", command)
+		return Command{}, nil, fmt.Errorf("%w: %s", ErrUnknownCommand, name)
 	}
 
-	// Validate the path before executing the command
-	if err := cf.validatePath(path); err != nil {
-		return nil, fmt.Errorf("invalid path: %v", err)
+	args, err := command.Parse(params)
+	if err != nil {
+		return Command{}, nil, err
 	}
 
-	switch command {
-	case "CHECK_DIRECTORY_FILE":
-		return cf.FetchFileInfo(path)
-	case "CHECK_FILE_PERMISSION":
-		return cf.FetchFilePermissions(path)
-	case "CHECK_FILE_TYPE":
-		return cf.FetchFileType(path)
-	case "CHECK_IS_FILE_TYPE":
-		return cf.FetchIsFile(path)
-	case "CHECK_FILE_DATES":
-		return cf.FetchFileDate(path)
-	case "CHECK_IF_MODIFIED_FILE":
-		return cf.FetchFileIsModified(path)
-	default:
-		return nil, fmt.Errorf("unknown command: %s", command)
+	// Validate the paths before executing the command, which then runs on the resolved paths that were checked
+	for _, param := range command.Params {
+		if path, ok := args[param.Name]; ok && param.Type == ParamPath {
+			resolved, err := cf.CheckAccess(command.Name, path)
+			if err != nil {
+				return Command{}, nil, err
+			}
+			args[param.Name] = resolved
+		}
 	}
+
+	return command, args, nil
 }
@@ -0,0 +1,344 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thespider911/filetrackermodification/app/internal/helpers"
+)
+
+// sort keys and orders of LIST_DIRECTORY and TREE
+const (
+	SortName  = "name"
+	SortSize  = "size"
+	SortMtime = "mtime"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// maxTreeEntries - most entries one TREE returns, the directories left once it is reached are not walked
+const maxTreeEntries = 10000
+
+var ErrNoDirectory = errors.New("models: no such existing directory record found")
+
+// Totals - count and size of the files below a directory, size is human readable and bytes exact
+type Totals struct {
+	Files       int    `json:"files"`
+	Directories int    `json:"directories"`
+	Size        string `json:"size"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// DirectoryListing - one page of the children of a directory, next is the offset of the following page, 0 on the last one
+type DirectoryListing struct {
+	Path    string     `json:"path"`
+	Entries []FileInfo `json:"entries"`
+	Count   int        `json:"count"`
+	Offset  int        `json:"offset"`
+	Limit   int        `json:"limit"`
+	Next    int        `json:"next,omitempty"`
+	Totals  Totals     `json:"totals"`
+}
+
+// TreeNode - an entry of a TREE, a directory has the totals of everything below it, walked or not.
+// Truncated is set when some entries below are left out because of the depth or entry limit,
+// denied when the directory is under an allowed root that does not permit TREE.
+type TreeNode struct {
+	FileInfo
+	Totals    *Totals     `json:"totals,omitempty"`
+	Children  []*TreeNode `json:"children,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Denied    bool        `json:"denied,omitempty"`
+}
+
+// ListDirectory - one page of the children of dirPath sorted by sortBy, with the totals of everything below it.
+// The directories under an allowed root that does not permit LIST_DIRECTORY are counted but not their contents.
+func (cf *CommandFileInfo) ListDirectory(ctx context.Context, dirPath, sortBy, order string, offset, limit int) (*DirectoryListing, error) {
+	dirPath = filepath.Clean(dirPath)
+	if _, err := cf.fetchDirectory(ctx, dirPath); err != nil {
+		return nil, err
+	}
+
+	entries, err := cf.listEntries(ctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	sortEntries(entries, sortBy, order)
+
+	listing := &DirectoryListing{Path: dirPath, Count: len(entries), Offset: offset, Limit: limit, Entries: []FileInfo{}}
+	if err := cf.newTreeWalker(ctx, "LIST_DIRECTORY").countBelow(&listing.Totals, entries); err != nil {
+		return nil, err
+	}
+
+	if offset < len(entries) {
+		end := offset + limit
+		if end < len(entries) {
+			listing.Next = end
+		} else {
+			end = len(entries)
+		}
+		listing.Entries = entries[offset:end]
+	}
+	for i := range listing.Entries {
+		formatFileInfo(&listing.Entries[i])
+	}
+
+	return listing, nil
+}
+
+// Tree - the entries below dirPath up to depth levels, sorted by sortBy, with recursive totals.
+// The progress is the number of directories walked of those found so far.
+func (cf *CommandFileInfo) Tree(ctx context.Context, dirPath, sortBy, order string, depth int) (*TreeNode, error) {
+	dirInfo, err := cf.fetchDirectory(ctx, filepath.Clean(dirPath))
+	if err != nil {
+		return nil, err
+	}
+
+	w := cf.newTreeWalker(ctx, "TREE")
+	w.sortBy, w.order, w.depth = sortBy, order, depth
+
+	root := &TreeNode{FileInfo: *dirInfo}
+	if err := w.walk(root, 0); err != nil {
+		return nil, err
+	}
+	formatFileInfo(&root.FileInfo)
+
+	return root, nil
+}
+
+// newTreeWalker - a walker listing directories with osquery, walking those inside a root that permits command
+func (cf *CommandFileInfo) newTreeWalker(ctx context.Context, command string) *treeWalker {
+	return &treeWalker{
+		ctx: ctx,
+		list: func(dir string) ([]FileInfo, error) {
+			return cf.listEntries(ctx, dir)
+		},
+		allowed: func(dir string) bool {
+			_, err := cf.CheckAccess(command, dir)
+			return err == nil
+		},
+		found: 1,
+	}
+}
+
+// fetchDirectory - the raw file info of the path, which must be a directory
+func (cf *CommandFileInfo) fetchDirectory(ctx context.Context, dirPath string) (*FileInfo, error) {
+	query := "SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode FROM file WHERE path = ?;"
+
+	var rows []FileInfo
+	if err := cf.osquery.QueryInto(ctx, &rows, query, dirPath); err != nil {
+		return nil, err
+	}
+
+	if len(rows) != 1 || rows[0].Type != "directory" {
+		return nil, ErrNoDirectory
+	}
+
+	return &rows[0], nil
+}
+
+// listEntries - the children of a directory with their raw osquery values
+func (cf *CommandFileInfo) listEntries(ctx context.Context, dirPath string) ([]FileInfo, error) {
+	query := "SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode FROM file WHERE directory = ?;"
+
+	var rows []FileInfo
+	if err := cf.osquery.QueryInto(ctx, &rows, query, dirPath); err != nil {
+		return nil, err
+	}
+
+	entries := rows[:0]
+	for _, row := range rows {
+		if row.Filename != "." && row.Filename != ".." && row.Path != dirPath {
+			entries = append(entries, row)
+		}
+	}
+
+	return entries, nil
+}
+
+// treeWalker - walks a TREE depth first, counting the entries and directories
+type treeWalker struct {
+	ctx     context.Context
+	list    func(dirPath string) ([]FileInfo, error)
+	allowed func(dirPath string) bool
+	sortBy  string
+	order   string
+	depth   int
+	entries int
+	walked  int
+	found   int
+}
+
+// walk - list the children of node and walk its directories, node is level levels below the root
+func (w *treeWalker) walk(node *TreeNode, level int) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	all, err := w.list(node.Path)
+	if err != nil {
+		return err
+	}
+	sortEntries(all, w.sortBy, w.order)
+
+	entries := all
+	if left := maxTreeEntries - w.entries; len(entries) > left {
+		entries, node.Truncated = entries[:left], true
+	}
+	w.entries += len(entries)
+
+	// the directories walked below this one are counted before they are walked, for the progress
+	walk := make([]bool, len(entries))
+	for i, entry := range entries {
+		if entry.Type == "directory" && level+1 < w.depth && w.allowed(entry.Path) {
+			walk[i] = true
+			w.found++
+		}
+	}
+	w.walked++
+	ReportProgress(w.ctx, w.walked, w.found)
+
+	// the entries left out by the entry limit are still counted
+	node.Totals = &Totals{}
+	if err := w.countBelow(node.Totals, all[len(entries):]); err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		child := &TreeNode{FileInfo: entry}
+		node.Totals.add(entry)
+		node.Children = append(node.Children, child)
+
+		if entry.Type != "directory" {
+			continue
+		}
+
+		switch {
+		case walk[i] && w.entries < maxTreeEntries:
+			if err := w.walk(child, level+1); err != nil {
+				return err
+			}
+		case walk[i]:
+			// the entry limit was reached while walking the directories before this one
+			child.Truncated = true
+			w.found--
+			ReportProgress(w.ctx, w.walked, w.found)
+		case level+1 < w.depth:
+			child.Truncated, child.Denied = true, true
+		default:
+			child.Truncated = true
+		}
+		if child.Truncated {
+			node.Truncated = true
+		}
+
+		// a directory that was not walked is counted to the bottom without returning its entries
+		if child.Totals == nil && !child.Denied && (walk[i] || w.allowed(child.Path)) {
+			below, err := w.list(child.Path)
+			if err != nil {
+				return err
+			}
+			child.Totals = &Totals{}
+			if err := w.countBelow(child.Totals, below); err != nil {
+				return err
+			}
+		}
+		if child.Totals != nil {
+			node.Totals.Files += child.Totals.Files
+			node.Totals.Directories += child.Totals.Directories
+			node.Totals.Bytes += child.Totals.Bytes
+		}
+	}
+	node.Totals.format()
+
+	for _, child := range node.Children {
+		formatFileInfo(&child.FileInfo)
+	}
+
+	return nil
+}
+
+// countBelow - add the entries and everything below their directories to the totals, listing the directories
+// one after the other without keeping their entries. Denied directories are counted but not their contents.
+func (w *treeWalker) countBelow(totals *Totals, entries []FileInfo) error {
+	for len(entries) > 0 {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		entry := entries[len(entries)-1]
+		entries = entries[:len(entries)-1]
+		totals.add(entry)
+		if entry.Type != "directory" || !w.allowed(entry.Path) {
+			continue
+		}
+
+		below, err := w.list(entry.Path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, below...)
+	}
+	totals.format()
+
+	return nil
+}
+
+// add - count an entry, the size of a directory itself is not part of the totals
+func (t *Totals) add(entry FileInfo) {
+	if entry.Type == "directory" {
+		t.Directories++
+		return
+	}
+
+	t.Files++
+	t.Bytes += parseInt(entry.Size)
+}
+
+// format - fill the human readable size
+func (t *Totals) format() {
+	t.Size = helpers.ToHumanReadableFileSize(strconv.FormatInt(t.Bytes, 10))
+}
+
+// sortEntries - sort raw entries by name, size or mtime, ties by name
+func sortEntries(entries []FileInfo, sortBy, order string) {
+	less := func(a, b FileInfo) bool {
+		switch sortBy {
+		case SortSize:
+			if x, y := parseInt(a.Size), parseInt(b.Size); x != y {
+				return x < y
+			}
+		case SortMtime:
+			if x, y := parseInt(a.Mtime), parseInt(b.Mtime); x != y {
+				return x < y
+			}
+		}
+		return strings.ToLower(a.Filename) < strings.ToLower(b.Filename)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order == OrderDesc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// formatFileInfo - format the raw time and size values of an entry as FetchFileInfo does
+func formatFileInfo(fileInfo *FileInfo) {
+	fileInfo.Mtime = helpers.ToHumanReadableTime(fileInfo.Mtime)
+	fileInfo.ATime = helpers.ToHumanReadableTime(fileInfo.ATime)
+	fileInfo.CTime = helpers.ToHumanReadableTimeDiff(fileInfo.CTime)
+	fileInfo.Size = helpers.ToHumanReadableFileSize(fileInfo.Size)
+}
+
+// parseInt - the number osquery returned, 0 when it is empty
+func parseInt(val string) int64 {
+	n, _ := strconv.ParseInt(val, 10, 64)
+	return n
+}
@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSortEntries(t *testing.T) {
+	entries := func() []FileInfo {
+		return []FileInfo{
+			{Filename: "b.txt", Size: "10", Mtime: "300"},
+			{Filename: "A.txt", Size: "30", Mtime: "100"},
+			{Filename: "c.txt", Size: "20", Mtime: "200"},
+		}
+	}
+
+	tests := []struct {
+		sortBy   string
+		order    string
+		expected []string
+	}{
+		{SortName, OrderAsc, []string{"A.txt", "b.txt", "c.txt"}},
+		{SortName, OrderDesc, []string{"c.txt", "b.txt", "A.txt"}},
+		{SortSize, OrderAsc, []string{"b.txt", "c.txt", "A.txt"}},
+		{SortSize, OrderDesc, []string{"A.txt", "c.txt", "b.txt"}},
+		{SortMtime, OrderAsc, []string{"A.txt", "c.txt", "b.txt"}},
+	}
+	for _, test := range tests {
+		list := entries()
+		sortEntries(list, test.sortBy, test.order)
+		for i, name := range test.expected {
+			if list[i].Filename != name {
+				t.Errorf("%s %s: got %s at %d; want %s", test.sortBy, test.order, list[i].Filename, i, name)
+			}
+		}
+	}
+}
+
+// entry - a raw directory entry as osquery returns it
+func entry(path, fileType, size string) FileInfo {
+	return FileInfo{Path: path, Filename: filepath.Base(path), Type: fileType, Size: size, Mtime: "0", ATime: "0", CTime: "0"}
+}
+
+func TestTreeWalk(t *testing.T) {
+	dirs := map[string][]FileInfo{
+		"/data": {
+			entry("/data/a.txt", "regular", "100"),
+			entry("/data/logs", "directory", "4096"),
+			entry("/data/secret", "directory", "4096"),
+		},
+		"/data/logs": {
+			entry("/data/logs/1.log", "regular", "10"),
+			entry("/data/logs/old", "directory", "4096"),
+		},
+		"/data/logs/old": {
+			entry("/data/logs/old/0.log", "regular", "5"),
+		},
+	}
+
+	var progress [][2]int
+	ctx := WithProgress(context.Background(), func(done, total int) { progress = append(progress, [2]int{done, total}) })
+
+	walk := func(depth int) *TreeNode {
+		w := &treeWalker{
+			ctx:     ctx,
+			list:    func(dir string) ([]FileInfo, error) { return append([]FileInfo(nil), dirs[dir]...), nil },
+			allowed: func(dir string) bool { return dir != "/data/secret" },
+			sortBy:  SortName,
+			order:   OrderAsc,
+			depth:   depth,
+			found:   1,
+		}
+		root := &TreeNode{FileInfo: entry("/data", "directory", "4096")}
+		if err := w.walk(root, 0); err != nil {
+			t.Fatalf("Failed to walk: %v", err)
+		}
+		return root
+	}
+
+	root := walk(3)
+	if root.Totals.Files != 3 || root.Totals.Directories != 3 || root.Totals.Bytes != 115 {
+		t.Errorf("Expected the recursive totals of the walked tree, got %+v", *root.Totals)
+	}
+	if !root.Truncated || len(root.Children) != 3 {
+		t.Errorf("Expected 3 children and a truncated tree because of the denied directory, got %d %v", len(root.Children), root.Truncated)
+	}
+	if secret := root.Children[2]; !secret.Denied || secret.Children != nil {
+		t.Errorf("Expected the denied directory not to be walked, got %+v", secret)
+	}
+	if old := root.Children[1].Children[1]; old.Totals == nil || old.Totals.Files != 1 {
+		t.Errorf("Expected the third level to be walked, got %+v", old)
+	}
+	if last := progress[len(progress)-1]; last != [2]int{3, 3} {
+		t.Errorf("Expected the progress to end at 3 of 3 directories, got %v", progress)
+	}
+
+	// the totals do not depend on the depth
+	root = walk(1)
+	if logs := root.Children[1]; logs.Children != nil || !logs.Truncated || logs.Totals == nil || logs.Totals.Files != 2 || logs.Totals.Bytes != 15 {
+		t.Errorf("Expected only the first level to be walked, with the totals below it, got %+v", logs)
+	}
+	if root.Totals.Files != 3 || root.Totals.Directories != 3 || root.Totals.Bytes != 115 {
+		t.Errorf("Expected the recursive totals of the whole tree, got %+v", *root.Totals)
+	}
+	if secret := root.Children[2]; secret.Totals != nil {
+		t.Errorf("Expected the denied directory not to be counted, got %+v", secret.Totals)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &treeWalker{ctx: cancelled, list: func(string) ([]FileInfo, error) { return nil, nil }, depth: 1}
+	if err := w.walk(&TreeNode{}, 0); err == nil {
+		t.Error("Expected a cancelled walk to fail")
+	}
+}
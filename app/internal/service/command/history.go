@@ -0,0 +1,148 @@
+package command
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/helpers"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+)
+
+var ErrNoHistory = errors.New("no history is recorded for this path")
+
+// History - the scan results stored for a path, oldest first
+type History interface {
+	Scans(path string) ([]eventstore.ScanRecord, error)
+}
+
+// Change - a field that differs from the version before, with its old and new value
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// FileVersion - one recorded state of a path, changes are empty for the first one and the one after a removal.
+// A removal has only its event, deleted or renamed, and the path it was renamed to.
+type FileVersion struct {
+	ID        uint64    `json:"id"`
+	ScanTime  time.Time `json:"scan_time"`
+	Event     string    `json:"event,omitempty"`
+	RenamedTo string    `json:"renamed_to,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Size      string    `json:"size,omitempty"`
+	Mode      string    `json:"mode,omitempty"`
+	Uid       string    `json:"uid,omitempty"`
+	Inode     string    `json:"inode,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	Mtime     string    `json:"mtime,omitempty"`
+	ATime     string    `json:"atime,omitempty"`
+	CTime     string    `json:"ctime,omitempty"`
+	Changes   []Change  `json:"changes,omitempty"`
+}
+
+// FileHistory - every recorded state transition of a path, with its deletions and renames, oldest first
+type FileHistory struct {
+	Path     string        `json:"path"`
+	Count    int           `json:"count"`
+	Versions []FileVersion `json:"versions"`
+}
+
+// FetchFileHistory - the recorded versions of a path with the diff of each against the one before.
+// A deleted path keeps its history, so it only has to be inside an allowed root that permits FILE_HISTORY.
+func (cf *CommandFileInfo) FetchFileHistory(filePath string) (*FileHistory, error) {
+	filePath = filepath.Clean(filePath)
+	if err := cf.checkHistoryAccess(filePath); err != nil {
+		return nil, err
+	}
+
+	if cf.history == nil {
+		return nil, ErrNoHistory
+	}
+
+	scans, err := cf.history.Scans(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(scans) == 0 {
+		return nil, ErrNoHistory
+	}
+
+	history := &FileHistory{Path: filePath, Count: len(scans), Versions: make([]FileVersion, len(scans))}
+	for i, scan := range scans {
+		version := formatVersion(scan)
+		if i > 0 && scans[i-1].Removed == "" && scan.Removed == "" {
+			version.Changes = diffScans(scans[i-1], scan)
+		}
+		history.Versions[i] = version
+	}
+
+	return history, nil
+}
+
+// checkHistoryAccess - CheckAccess, except that a path which no longer exists is fine inside a root permitting the command
+func (cf *CommandFileInfo) checkHistoryAccess(filePath string) error {
+	_, err := cf.CheckAccess("FILE_HISTORY", filePath)
+
+	var accessErr *AccessError
+	if !errors.As(err, &accessErr) || accessErr.Code != CodePathNotFound {
+		return err
+	}
+
+	if root, _ := cf.rootOf(filePath, false); len(root.Commands) > 0 && !contains(root.Commands, "FILE_HISTORY") {
+		return &AccessError{Code: CodeCommandDenied, Path: filePath, Message: "FILE_HISTORY is not allowed under " + root.Path}
+	}
+
+	return nil
+}
+
+// formatVersion - the stored scan with its times and size human readable
+func formatVersion(scan eventstore.ScanRecord) FileVersion {
+	if scan.Removed != "" {
+		return FileVersion{ID: scan.ID, ScanTime: scan.Time, Event: string(scan.Removed), RenamedTo: scan.RenamedTo}
+	}
+
+	return FileVersion{
+		ID:       scan.ID,
+		ScanTime: scan.Time,
+		Type:     scan.FileType,
+		Size:     helpers.ToHumanReadableFileSize(scan.FileSize),
+		Mode:     scan.Permission,
+		Uid:      scan.Uid,
+		Inode:    scan.Inode,
+		Hash:     scan.Hash,
+		Mtime:    helpers.ToHumanReadableTime(scan.ModifiedTime),
+		ATime:    helpers.ToHumanReadableTime(scan.AccessedTime),
+		CTime:    helpers.ToHumanReadableTime(scan.ChangedTime),
+	}
+}
+
+// diffScans - the fields that differ between two consecutive scans, compared raw and shown like the versions.
+// The atime is left out, a new scan is not stored because of it.
+func diffScans(prev, next eventstore.ScanRecord) []Change {
+	same := func(val string) string { return val }
+	fields := []struct {
+		name     string
+		from, to string
+		format   func(string) string
+	}{
+		{"type", prev.FileType, next.FileType, same},
+		{"size", prev.FileSize, next.FileSize, helpers.ToHumanReadableFileSize},
+		{"mode", prev.Permission, next.Permission, same},
+		{"uid", prev.Uid, next.Uid, same},
+		{"inode", prev.Inode, next.Inode, same},
+		{"hash", prev.Hash, next.Hash, same},
+		{"mtime", prev.ModifiedTime, next.ModifiedTime, helpers.ToHumanReadableTime},
+		{"ctime", prev.ChangedTime, next.ChangedTime, helpers.ToHumanReadableTime},
+	}
+
+	var changes []Change
+	for _, field := range fields {
+		if field.from != field.to {
+			changes = append(changes, Change{Field: field.name, From: field.format(field.from), To: field.format(field.to)})
+		}
+	}
+
+	return changes
+}
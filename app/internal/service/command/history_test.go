@@ -0,0 +1,102 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/eventstore"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+)
+
+// scans - a History over a map of paths
+type scans map[string][]eventstore.ScanRecord
+
+func (s scans) Scans(path string) ([]eventstore.ScanRecord, error) {
+	return s[path], nil
+}
+
+// scan - a stored scan of path with the raw values osquery returns
+func scan(id uint64, path, size, mode, mtime string) eventstore.ScanRecord {
+	return eventstore.ScanRecord{ID: id, Time: time.Unix(int64(id), 0), FileInfo: filetrack.FileInfo{
+		Path: path, FileType: "regular", FileSize: size, Permission: mode, ModifiedTime: mtime, AccessedTime: mtime, ChangedTime: mtime,
+	}}
+}
+
+func TestFetchFileHistory(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	deleted := filepath.Join(dir, "gone.txt")
+	recreated := filepath.Join(dir, "again.txt")
+	restricted := filepath.Join(dir, "restricted")
+	if err := os.Mkdir(restricted, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+
+	history := scans{
+		file: {
+			scan(1, file, "1", "0644", "100"),
+			scan(2, file, "1", "0600", "100"),
+			scan(3, file, "2048", "0600", "200"),
+		},
+		deleted: {scan(4, deleted, "1", "0644", "100")},
+		recreated: {
+			scan(5, recreated, "1", "0644", "100"),
+			{ID: 6, Removed: snapshot.Deleted, FileInfo: filetrack.FileInfo{Path: recreated}},
+			scan(7, recreated, "5", "0644", "300"),
+		},
+	}
+	cf := NewCommandFileInfo(config.Config{AllowedRoots: []config.AllowedRoot{
+		{Path: dir},
+		{Path: restricted, Commands: []string{"CHECK_FILE_DATES"}},
+	}}, nil, history)
+
+	result, err := cf.FetchFileHistory(file)
+	if err != nil {
+		t.Fatalf("Failed to fetch history: %v", err)
+	}
+	if result.Count != 3 || result.Versions[0].Changes != nil {
+		t.Fatalf("Expected 3 versions and no changes for the first, got %+v", result)
+	}
+	if changes := result.Versions[1].Changes; len(changes) != 1 || changes[0] != (Change{Field: "mode", From: "0644", To: "0600"}) {
+		t.Errorf("Expected the mode change, got %+v", changes)
+	}
+	if changes := result.Versions[2].Changes; len(changes) != 3 || changes[0].Field != "size" || changes[0].To != "2.0 KB" {
+		t.Errorf("Expected the size, mtime and ctime changes, got %+v", changes)
+	}
+
+	// a deleted path keeps its history
+	if result, err := cf.FetchFileHistory(deleted); err != nil || result.Count != 1 {
+		t.Errorf("Expected the history of the deleted file, got %v", err)
+	}
+
+	// deleting and creating the file again is part of the history
+	result, err = cf.FetchFileHistory(recreated)
+	if err != nil || result.Count != 3 {
+		t.Fatalf("Expected the deletion between the two files, got %+v (%v)", result, err)
+	}
+	if removal := result.Versions[1]; removal.Event != "deleted" || removal.Size != "" || removal.Changes != nil {
+		t.Errorf("Expected a deletion without file info, got %+v", removal)
+	}
+	if result.Versions[2].Changes != nil {
+		t.Errorf("Expected the recreated file to start without changes, got %+v", result.Versions[2].Changes)
+	}
+
+	var accessErr *AccessError
+	if _, err := cf.FetchFileHistory(filepath.Join(restricted, "gone.txt")); !errors.As(err, &accessErr) || accessErr.Code != CodeCommandDenied {
+		t.Errorf("Expected a root without FILE_HISTORY to deny a deleted path, got %v", err)
+	}
+	if _, err := cf.FetchFileHistory("/etc/passwd"); !errors.As(err, &accessErr) || accessErr.Code != CodeOutsideRoots {
+		t.Errorf("Expected a path outside the roots to be denied, got %v", err)
+	}
+	if _, err := cf.FetchFileHistory(filepath.Join(dir, "never.txt")); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("Expected no history for a path that was never scanned, got %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// QueryResult - rows of an OSQUERY command, truncated when the query had more than limit rows
+type QueryResult struct {
+	Rows      []map[string]string `json:"rows"`
+	Count     int                 `json:"count"`
+	Limit     int                 `json:"limit"`
+	Truncated bool                `json:"truncated"`
+}
+
+// forbiddenWords - statements and functions that are never part of a read-only query
+var forbiddenWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "ATTACH": true, "DETACH": true,
+	"PRAGMA": true, "VACUUM": true, "REINDEX": true, "ANALYZE": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true,
+	"LOAD_EXTENSION": true, "READFILE": true, "WRITEFILE": true, "FTS3_TOKENIZER": true,
+}
+
+// words ending the table list of a FROM clause
+var fromEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "WINDOW": true,
+	"ON": true, "USING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "SELECT": true,
+}
+
+// RunQuery - run a single SELECT that only reads the allowed tables, returning at most limit rows.
+// A limit of 0 is the configured row limit, a larger one is rejected.
+func (cf *CommandFileInfo) RunQuery(ctx context.Context, sql string, limit int) (*QueryResult, error) {
+	switch {
+	case limit == 0:
+		limit = cf.rowLimit
+	case limit < 0 || limit > cf.rowLimit:
+		return nil, fmt.Errorf("limit must be between 1 and %d", cf.rowLimit)
+	}
+
+	query, err := cf.prepareQuery(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	// the whole inner query runs before the limit applies, so it is stopped after the query timeout
+	ctx, cancel := context.WithTimeout(ctx, cf.queryTimeout)
+	defer cancel()
+
+	// one row over the limit tells a truncated result apart
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	rows, err := cf.osquery.Query(ctx, fmt.Sprintf("SELECT * FROM (%s) LIMIT %d;", query, limit+1))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("the query took longer than %s", cf.queryTimeout)
+		}
+		return nil, err
+	}
+
+	result := &QueryResult{Rows: rows, Limit: limit}
+	if len(rows) > limit {
+		result.Rows, result.Truncated = rows[:limit], true
+	}
+	if result.Rows == nil {
+		result.Rows = []map[string]string{}
+	}
+	result.Count = len(result.Rows)
+
+	return result, nil
+}
+
+// prepareQuery - check that sql is one SELECT statement reading only the allowed tables, ignoring case,
+// and return the query to run, which reads the resolved paths for the file and hash tables
+func (cf *CommandFileInfo) prepareQuery(sql string) (string, error) {
+	tokens, tables, err := parseSelect(sql)
+	if err != nil {
+		return "", err
+	}
+	for _, table := range tables {
+		if !contains(cf.tables, table) {
+			return "", fmt.Errorf("table %s is not allowed, the allowed tables are %s", table, strings.Join(cf.tables, ", "))
+		}
+	}
+
+	// the file and hash tables only read paths inside the allowed roots
+	if readsFiles(tables) {
+		return cf.constrainPaths(sql, tokens, tables)
+	}
+
+	return sql, nil
+}
+
+// pathColumns - the columns that keep a query of the file and hash tables to the paths it names,
+// directory is left out for hash because it hashes the files a symlink in the directory points to
+var pathColumns = map[string][]string{
+	"file": {"path", "directory"},
+	"hash": {"path"},
+}
+
+// words ending the WHERE clause of a SELECT
+var whereEnd = map[string]bool{
+	"GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true, "WINDOW": true,
+}
+
+// readsFiles - whether one of the tables reads the paths it is given
+func readsFiles(tables []string) bool {
+	for _, table := range tables {
+		if _, ok := pathColumns[table]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// constrainPaths - check that a query of the file or hash table only reads paths inside the allowed roots
+// and put the resolved paths in place of the ones in the query, so it reads the paths that passed CheckAccess.
+// The table must be queried alone and its WHERE needs a condition path = 'path' or path IN ('path', ...)
+// joined to the others with AND, directory may take the place of path for the file table.
+func (cf *CommandFileInfo) constrainPaths(sql string, tokens []token, tables []string) (string, error) {
+	if len(tables) != 1 {
+		return "", errors.New("the file and hash tables can only be queried alone, without joins")
+	}
+	table := tables[0]
+	columns := pathColumns[table]
+
+	where, depth := -1, 0
+	for i, tok := range tokens {
+		switch {
+		case isPunct(tok, "("):
+			depth++
+		case isPunct(tok, ")"):
+			depth--
+		case tok.kind != tokenWord:
+		case i > 0 && strings.EqualFold(tok.text, "SELECT"), strings.EqualFold(tok.text, "VALUES"),
+			strings.EqualFold(tok.text, "UNION"), strings.EqualFold(tok.text, "INTERSECT"), strings.EqualFold(tok.text, "EXCEPT"):
+			return "", fmt.Errorf("the %s table can only be queried alone, without subqueries or compound selects", table)
+		case strings.EqualFold(tok.text, "CASE"), strings.EqualFold(tok.text, "BETWEEN"):
+			return "", fmt.Errorf("%s is not allowed in queries of the %s table", strings.ToUpper(tok.text), table)
+		case depth == 0 && strings.EqualFold(tok.text, "WHERE"):
+			where = i
+		}
+	}
+	required := fmt.Errorf("queries of the %s table need a WHERE condition %s = 'path' or %s IN ('path', ...) inside an allowed root",
+		table, strings.Join(columns, " or "), columns[0])
+	if where < 0 {
+		return "", required
+	}
+
+	// the conditions of the WHERE clause outside parentheses, split on AND
+	var conditions [][]token
+	begin := where + 1
+	depth = 0
+	for i := where + 1; i <= len(tokens); i++ {
+		if i == len(tokens) {
+			conditions = append(conditions, tokens[begin:i])
+			break
+		}
+		tok := tokens[i]
+		if isPunct(tok, "(") {
+			depth++
+		} else if isPunct(tok, ")") {
+			depth--
+		}
+		if depth != 0 || tok.kind != tokenWord {
+			continue
+		}
+		upper := strings.ToUpper(tok.text)
+		if upper == "OR" {
+			return "", fmt.Errorf("OR is only allowed inside parentheses in queries of the %s table", table)
+		}
+		if upper == "AND" || whereEnd[upper] {
+			conditions = append(conditions, tokens[begin:i])
+			begin = i + 1
+		}
+		if whereEnd[upper] {
+			break
+		}
+	}
+
+	var literals []token
+	for _, condition := range conditions {
+		literals = append(literals, pathLiterals(condition, columns)...)
+	}
+	if len(literals) == 0 {
+		return "", required
+	}
+
+	runes := []rune(sql)
+	var b strings.Builder
+	last := 0
+	for _, literal := range literals {
+		resolved, err := cf.CheckAccess("OSQUERY", literal.text)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(string(runes[last:literal.start]))
+		b.WriteString("'" + strings.ReplaceAll(resolved, "'", "''") + "'")
+		last = literal.end
+	}
+	b.WriteString(string(runes[last:]))
+
+	return b.String(), nil
+}
+
+// pathLiterals - the string literals of a condition column = 'path' or column IN ('path', ...) on one of columns,
+// nil for any other condition. The column may be qualified with the table name or alias.
+func pathLiterals(condition []token, columns []string) []token {
+	if len(condition) > 2 && isPunct(condition[1], ".") {
+		condition = condition[2:]
+	}
+	if len(condition) < 3 || (condition[0].kind != tokenWord && condition[0].kind != tokenQuoted) {
+		return nil
+	}
+	if !contains(columns, strings.ToLower(condition[0].text)) {
+		return nil
+	}
+
+	rest := condition[1:]
+	switch {
+	case isPunct(rest[0], "="):
+		if len(rest) == 3 && isPunct(rest[1], "=") {
+			rest = rest[1:]
+		}
+		if len(rest) == 2 && rest[1].kind == tokenString {
+			return rest[1:]
+		}
+	case rest[0].kind == tokenWord && strings.EqualFold(rest[0].text, "IN"):
+		// ( 'a' , 'b' ) alternates literals and commas between the parentheses
+		list := rest[1:]
+		if len(list) < 3 || len(list)%2 == 0 || !isPunct(list[0], "(") || !isPunct(list[len(list)-1], ")") {
+			return nil
+		}
+		var literals []token
+		for i, tok := range list[1 : len(list)-1] {
+			switch {
+			case i%2 == 0 && tok.kind == tokenString:
+				literals = append(literals, tok)
+			case i%2 == 1 && isPunct(tok, ","):
+			default:
+				return nil
+			}
+		}
+		return literals
+	}
+
+	return nil
+}
+
+// parseSelect - the tokens of a single read-only SELECT statement, without its closing semicolon,
+// and the tables it reads in lower case, once for every time a table is named
+func parseSelect(sql string) ([]token, []string, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].text == ";" && tokens[n-1].kind == tokenPunct {
+		tokens = tokens[:n-1]
+	}
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("sql is required")
+	}
+	if tokens[0].kind != tokenWord || !strings.EqualFold(tokens[0].text, "SELECT") {
+		return nil, nil, errors.New("only SELECT queries can run")
+	}
+
+	var tables []string
+
+	// inFrom tracks, per parenthesis depth, whether a comma starts another table of a FROM clause
+	inFrom := []bool{false}
+	expectTable := false
+	for i, tok := range tokens {
+		depth := len(inFrom) - 1
+
+		switch tok.kind {
+		case tokenPunct:
+			switch tok.text {
+			case ";":
+				return nil, nil, errors.New("only a single statement can run")
+			case "?":
+				return nil, nil, errors.New("placeholders are not supported, write the values as literals")
+			case "(":
+				// a subquery in place of a table
+				expectTable = false
+				inFrom = append(inFrom, false)
+			case ")":
+				if depth == 0 {
+					return nil, nil, errors.New("unbalanced parentheses")
+				}
+				inFrom = inFrom[:depth]
+			case ",":
+				if inFrom[depth] {
+					expectTable = true
+				}
+			default:
+				if expectTable {
+					return nil, nil, errors.New("a table name is required after FROM and JOIN")
+				}
+			}
+			continue
+		case tokenString, tokenNumber:
+			if expectTable {
+				return nil, nil, errors.New("a table name is required after FROM and JOIN")
+			}
+			continue
+		}
+
+		upper := strings.ToUpper(tok.text)
+		if tok.kind == tokenWord && forbiddenWords[upper] {
+			return nil, nil, fmt.Errorf("%s is not allowed, queries are read-only", upper)
+		}
+
+		if expectTable {
+			expectTable = false
+			if i+1 < len(tokens) && tokens[i+1].text == "." && tokens[i+1].kind == tokenPunct {
+				return nil, nil, fmt.Errorf("qualified table names are not allowed: %s", tok.text)
+			}
+			tables = append(tables, strings.ToLower(tok.text))
+			continue
+		}
+
+		if tok.kind != tokenWord {
+			continue
+		}
+		switch {
+		case upper == "FROM" || upper == "JOIN":
+			expectTable = true
+			inFrom[depth] = true
+		case fromEnd[upper]:
+			inFrom[depth] = false
+		}
+	}
+
+	if len(inFrom) != 1 {
+		return nil, nil, errors.New("unbalanced parentheses")
+	}
+	if expectTable {
+		return nil, nil, errors.New("a table name is required after FROM and JOIN")
+	}
+
+	return tokens, tables, nil
+}
+
+// token kinds of the sql tokenizer
+const (
+	tokenWord = iota
+	tokenQuoted
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+// token - a word, quoted identifier, string literal, number or punctuation character of sql,
+// start and end are its rune offsets in the sql
+type token struct {
+	kind  int
+	text  string
+	start int
+	end   int
+}
+
+// tokenize - split sql into tokens, quoted identifiers are returned without their quotes
+func tokenize(sql string) ([]token, error) {
+	var tokens []token
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-', r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			return nil, errors.New("comments are not allowed")
+		case r == '\'' || r == '"' || r == '`' || r == '[':
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			text, next, err := quoted(runes, i, closing)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenQuoted
+			if r == '\'' {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind: kind, text: text, start: i, end: next})
+			i = next
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '$' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[start:i]), start: start, end: i})
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), start: start, end: i})
+		default:
+			tokens = append(tokens, token{kind: tokenPunct, text: string(r), start: i, end: i + 1})
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+// isPunct - whether tok is the punctuation character text
+func isPunct(tok token, text string) bool {
+	return tok.kind == tokenPunct && tok.text == text
+}
+
+// quoted - the text between the quote at start and its closing quote, a doubled closing quote is an escaped one.
+// Returns the index after the closing quote.
+func quoted(runes []rune, start int, closing rune) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] != closing {
+			b.WriteRune(runes[i])
+			continue
+		}
+		if closing != ']' && i+1 < len(runes) && runes[i+1] == closing {
+			b.WriteRune(closing)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+
+	return "", 0, errors.New("unterminated quote")
+}
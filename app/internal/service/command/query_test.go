@@ -0,0 +1,147 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// TestPrepareQuery - the checks RunQuery makes before a query reaches osquery
+func TestPrepareQuery(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve test directory: %v", err)
+	}
+	cf := &CommandFileInfo{
+		tables: []string{"file", "hash", "processes", "users", "mounts"},
+		roots:  []config.AllowedRoot{{Path: root}},
+	}
+
+	tests := []struct {
+		name string
+		sql  string
+		ok   bool
+	}{
+		{"select", "SELECT path, size FROM file WHERE directory = '" + root + "';", true},
+		{"lower case", "select * from Users", true},
+		{"join", "SELECT * FROM users u JOIN processes p USING (uid)", true},
+		{"table list", "SELECT * FROM users u, processes p WHERE u.uid = p.uid", true},
+		{"subquery", "SELECT * FROM (SELECT pid, name FROM processes) WHERE pid IN (SELECT pid FROM processes)", true},
+		{"quoted table", `SELECT * FROM "mounts"`, true},
+		{"keyword in literal", "SELECT * FROM users WHERE username = 'drop; delete'", true},
+		{"no table", "SELECT 1", true},
+		{"empty", " ; ", false},
+		{"not select", "PRAGMA table_info(file)", false},
+		{"table not allowed", "SELECT * FROM shell_history", false},
+		{"joined table not allowed", "SELECT * FROM users JOIN shell_history USING (uid)", false},
+		{"listed table not allowed", "SELECT * FROM users, sqlite_master", false},
+		{"subquery table not allowed", "SELECT * FROM users WHERE uid IN (SELECT uid FROM shadow)", false},
+		{"quoted table not allowed", "SELECT * FROM [sqlite_master]", false},
+		{"qualified table", "SELECT * FROM main.file", false},
+		{"file join", "SELECT f.path, h.sha256 FROM file f JOIN hash h USING (path) WHERE f.directory = '" + root + "'", false},
+		{"file outside roots", "SELECT * FROM file WHERE directory = '/'", false},
+		{"two statements", "SELECT * FROM users; SELECT * FROM file", false},
+		{"write", "SELECT * FROM users WHERE 1 = 1 UNION SELECT load_extension('x')", false},
+		{"comment", "SELECT * FROM users -- all of them", false},
+		{"block comment", "SELECT * FROM /* hidden */ users", false},
+		{"placeholder", "SELECT * FROM file WHERE path = ?", false},
+		{"unterminated", "SELECT * FROM file WHERE path = '/tmp", false},
+		{"unbalanced", "SELECT * FROM (SELECT * FROM users", false},
+		{"missing table", "SELECT * FROM", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cf.prepareQuery(tt.sql)
+			if tt.ok && err != nil {
+				t.Errorf("Expected %q to be allowed, got %v", tt.sql, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("Expected %q to be rejected", tt.sql)
+			}
+		})
+	}
+}
+
+func TestRunQueryLimit(t *testing.T) {
+	cf := &CommandFileInfo{tables: []string{"users"}, rowLimit: 100}
+
+	for _, limit := range []int{-1, 101} {
+		if _, err := cf.RunQuery(context.Background(), "SELECT * FROM users", limit); err == nil {
+			t.Errorf("Expected limit %d to be rejected", limit)
+		}
+	}
+}
+
+func TestConstrainPaths(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve test directory: %v", err)
+	}
+	outside := t.TempDir()
+	for _, path := range []string{filepath.Join(root, "a.txt"), filepath.Join(root, "b'.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "escape.txt")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	cf := &CommandFileInfo{roots: []config.AllowedRoot{{Path: root}}}
+	a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b''.txt")
+	secret := filepath.Join(outside, "secret.txt")
+
+	tests := []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{"path", "SELECT * FROM file WHERE path = '" + a + "'", "SELECT * FROM file WHERE path = '" + a + "'"},
+		{"qualified", "SELECT f.path FROM file f WHERE f.path == '" + a + "' ORDER BY 1", "SELECT f.path FROM file f WHERE f.path == '" + a + "' ORDER BY 1"},
+		{"in", "SELECT sha256 FROM hash WHERE path IN ('" + a + "', '" + b + "')", "SELECT sha256 FROM hash WHERE path IN ('" + a + "', '" + b + "')"},
+		{"directory", "SELECT * FROM file WHERE size > 0 AND directory = '" + root + "' AND (mode = '0644' OR uid = 0)",
+			"SELECT * FROM file WHERE size > 0 AND directory = '" + root + "' AND (mode = '0644' OR uid = 0)"},
+		{"link", "SELECT * FROM file WHERE path = '" + filepath.Join(root, "link.txt") + "'", "SELECT * FROM file WHERE path = '" + a + "'"},
+		{"symlink out of root", "SELECT * FROM file WHERE path = '" + filepath.Join(root, "escape.txt") + "'", ""},
+		{"outside", "SELECT * FROM file WHERE path = '" + secret + "'", ""},
+		{"one outside", "SELECT sha256 FROM hash WHERE path IN ('" + a + "', '" + secret + "')", ""},
+		{"no where", "SELECT * FROM file", ""},
+		{"like", "SELECT * FROM file WHERE path LIKE '" + root + "/%'", ""},
+		{"or", "SELECT * FROM file WHERE path = '" + a + "' OR path = '" + secret + "'", ""},
+		{"negated", "SELECT * FROM file WHERE NOT path = '" + a + "'", ""},
+		{"hash directory", "SELECT sha256 FROM hash WHERE directory = '" + root + "'", ""},
+		{"subquery", "SELECT * FROM file WHERE path = '" + a + "' AND uid IN (SELECT uid FROM users)", ""},
+		{"join", "SELECT * FROM file JOIN users USING (uid) WHERE path = '" + a + "'", ""},
+		{"between", "SELECT * FROM file WHERE size BETWEEN 1 AND path = '" + a + "'", ""},
+		{"collate", "SELECT * FROM file WHERE path = '" + a + "' COLLATE nocase", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, tables, err := parseSelect(tt.sql)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.sql, err)
+			}
+			query, err := cf.constrainPaths(tt.sql, tokens, tables)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("Expected %q to be rejected, got %q", tt.sql, query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected %q to be allowed, got %v", tt.sql, err)
+			}
+			if query != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, query)
+			}
+		})
+	}
+}
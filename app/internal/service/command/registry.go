@@ -0,0 +1,273 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// parameter types, a path is checked against the allowed roots before the command runs
+const (
+	ParamString = "string"
+	ParamPath   = "path"
+	ParamInt    = "int"
+	ParamBool   = "bool"
+)
+
+// roles a command can require, the roles of the api keys
+const (
+	RoleRead     = "read"
+	RoleOperator = "operator"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// commandName - upper case words joined by underscores, e.g. CHECK_FILE_DATES
+var commandName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Param - a typed parameter of a command. Ints are at least Min and, when Max is set, at most Max.
+type Param struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	Default     string   `json:"default,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	Min         int      `json:"min,omitempty"`
+	Max         int      `json:"max,omitempty"`
+	Description string   `json:"description"`
+}
+
+// Handler - runs a command with its validated arguments
+type Handler func(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error)
+
+// Command - a command /execute runs, /help describes it from the same definition
+type Command struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Usage       string  `json:"usage"`
+	Role        string  `json:"role"`
+	Params      []Param `json:"params"`
+	Handler     Handler `json:"-"`
+}
+
+// Args - the validated parameters of a command, with the defaults filled in
+type Args map[string]string
+
+// String - the value of the parameter, empty when it was not given
+func (a Args) String(name string) string {
+	return a[name]
+}
+
+// Int - the value of an int parameter, 0 when it was not given
+func (a Args) Int(name string) int {
+	n, _ := strconv.Atoi(a[name])
+	return n
+}
+
+// Bool - the value of a bool parameter, false when it was not given
+func (a Args) Bool(name string) bool {
+	b, _ := strconv.ParseBool(a[name])
+	return b
+}
+
+// progressKey - context key of the progress reporter of a background job
+type progressKey struct{}
+
+// WithProgress - ctx with fn receiving the progress a command reports
+func WithProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress - report done of total steps of a long command, ignored when it does not run as a job
+func ReportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(func(done, total int)); ok {
+		fn(done, total)
+	}
+}
+
+// Registry - the commands /execute runs, by name
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+// NewRegistry - new empty registry
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]Command)}
+}
+
+// defaultRegistry - holds the built-in commands and those other packages register in their init
+var defaultRegistry = NewRegistry()
+
+// Default - the registry Register adds to
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register - add a command to the default registry, panics when the definition is invalid or the name is taken
+func Register(c Command) {
+	if err := defaultRegistry.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Register - add a command, the role defaults to operator and the usage is generated when not given
+func (r *Registry) Register(c Command) error {
+	if !commandName.MatchString(c.Name) {
+		return fmt.Errorf("command name %q must be upper case words joined by underscores", c.Name)
+	}
+	if c.Handler == nil {
+		return fmt.Errorf("command %s has no handler", c.Name)
+	}
+
+	switch c.Role {
+	case "":
+		c.Role = RoleOperator
+	case RoleRead, RoleOperator:
+	default:
+		return fmt.Errorf("command %s has an unknown role %q", c.Name, c.Role)
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range c.Params {
+		switch {
+		case p.Name == "" || p.Name == "command":
+			return fmt.Errorf("command %s has a parameter named %q", c.Name, p.Name)
+		case seen[p.Name]:
+			return fmt.Errorf("command %s has the parameter %s twice", c.Name, p.Name)
+		}
+		switch p.Type {
+		case ParamString, ParamPath, ParamInt, ParamBool:
+		default:
+			return fmt.Errorf("parameter %s of %s has an unknown type %q", p.Name, c.Name, p.Type)
+		}
+		seen[p.Name] = true
+	}
+
+	if c.Usage == "" {
+		c.Usage = usage(c)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.commands[c.Name]; ok {
+		return fmt.Errorf("command %s is already registered", c.Name)
+	}
+	r.commands[c.Name] = c
+
+	return nil
+}
+
+// Lookup - the command by name, ignoring case
+func (r *Registry) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.commands[strings.ToUpper(name)]
+	return c, ok
+}
+
+// Commands - every registered command, sorted by name
+func (r *Registry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	commands := make([]Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		commands = append(commands, c)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	return commands
+}
+
+// Parse - check params against the parameters of the command, returning them with the defaults filled in
+func (c Command) Parse(params map[string]string) (Args, error) {
+	args := make(Args, len(c.Params))
+	known := make(map[string]bool, len(c.Params))
+	for _, p := range c.Params {
+		known[p.Name] = true
+
+		value, ok := params[p.Name]
+		if !ok || value == "" {
+			if p.Required {
+				return nil, fmt.Errorf("%s requires a '%s' parameter", c.Name, p.Name)
+			}
+			if p.Default == "" {
+				continue
+			}
+			value = p.Default
+		}
+
+		value, err := p.check(value)
+		if err != nil {
+			return nil, err
+		}
+		args[p.Name] = value
+	}
+
+	for name := range params {
+		if !known[name] {
+			return nil, fmt.Errorf("%s has no '%s' parameter", c.Name, name)
+		}
+	}
+
+	return args, nil
+}
+
+// check - whether value is valid for the parameter type and limits, returning it as the handler gets it:
+// one of Values is matched ignoring case and passed on as it is listed
+func (p Param) check(value string) (string, error) {
+	switch p.Type {
+	case ParamInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number", p.Name)
+		}
+		if n < p.Min || (p.Max > 0 && n > p.Max) {
+			if p.Max > 0 {
+				return "", fmt.Errorf("%s must be between %d and %d", p.Name, p.Min, p.Max)
+			}
+			return "", fmt.Errorf("%s must be at least %d", p.Name, p.Min)
+		}
+	case ParamBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "", fmt.Errorf("%s must be true or false", p.Name)
+		}
+	}
+
+	if len(p.Values) == 0 {
+		return value, nil
+	}
+	for _, allowed := range p.Values {
+		if strings.EqualFold(allowed, value) {
+			return allowed, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.Values, ", "))
+}
+
+// usage - example /execute url of the command with its required parameters
+func usage(c Command) string {
+	var b strings.Builder
+	b.WriteString("/execute?command=" + c.Name)
+	for _, p := range c.Params {
+		if !p.Required {
+			continue
+		}
+		example := "<" + p.Name + ">"
+		if p.Type == ParamPath {
+			example = "/path/to/file"
+		}
+		b.WriteString("&" + p.Name + "=" + example)
+	}
+
+	return b.String()
+}
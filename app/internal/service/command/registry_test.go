@@ -0,0 +1,129 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// echo - returns its arguments
+func echo(ctx context.Context, cf *CommandFileInfo, args Args) (interface{}, error) {
+	return args, nil
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+
+	if err := r.Register(Command{Name: "ECHO", Handler: echo, Params: []Param{{Name: "path", Type: ParamPath, Required: true}}}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	c, ok := r.Lookup("echo")
+	if !ok {
+		t.Fatal("Expected the command to be found ignoring case")
+	}
+	if c.Role != RoleOperator {
+		t.Errorf("Expected the operator role by default, got %s", c.Role)
+	}
+	if c.Usage != "/execute?command=ECHO&path=/path/to/file" {
+		t.Errorf("Expected a generated usage, got %s", c.Usage)
+	}
+
+	invalid := []Command{
+		{Name: "ECHO", Handler: echo},
+		{Name: "lower_case", Handler: echo},
+		{Name: "NO_HANDLER"},
+		{Name: "BAD_ROLE", Handler: echo, Role: "admin"},
+		{Name: "BAD_TYPE", Handler: echo, Params: []Param{{Name: "n", Type: "float"}}},
+		{Name: "TWICE", Handler: echo, Params: []Param{{Name: "n", Type: ParamInt}, {Name: "n", Type: ParamInt}}},
+		{Name: "RESERVED", Handler: echo, Params: []Param{{Name: "command", Type: ParamString}}},
+	}
+	for _, c := range invalid {
+		if err := r.Register(c); err == nil {
+			t.Errorf("Expected %s to be rejected", c.Name)
+		}
+	}
+
+	// the built-in commands are in the default registry
+	if _, ok := Default().Lookup("CHECK_FILE_DATES"); !ok {
+		t.Error("Expected the built-in commands to be registered")
+	}
+}
+
+func TestParse(t *testing.T) {
+	c := Command{Name: "LIST", Params: []Param{
+		{Name: "path", Type: ParamPath, Required: true},
+		{Name: "depth", Type: ParamInt, Min: 1, Max: 5, Default: "1"},
+		{Name: "sort", Type: ParamString, Values: []string{"name", "size"}},
+		{Name: "all", Type: ParamBool},
+	}}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   Args
+	}{
+		{"defaults", map[string]string{"path": "/a"}, Args{"path": "/a", "depth": "1"}},
+		{"all", map[string]string{"path": "/a", "depth": "5", "sort": "SIZE", "all": "true"}, Args{"path": "/a", "depth": "5", "sort": "size", "all": "true"}},
+		{"missing", map[string]string{"depth": "2"}, nil},
+		{"unknown", map[string]string{"path": "/a", "color": "red"}, nil},
+		{"not a number", map[string]string{"path": "/a", "depth": "two"}, nil},
+		{"too deep", map[string]string{"path": "/a", "depth": "6"}, nil},
+		{"not a value", map[string]string{"path": "/a", "sort": "date"}, nil},
+		{"not a bool", map[string]string{"path": "/a", "all": "maybe"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := c.Parse(tt.params)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("Expected %v to be rejected", tt.params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			for key, value := range tt.want {
+				if args[key] != value {
+					t.Errorf("Expected %s=%s, got %s", key, value, args[key])
+				}
+			}
+			if len(args) != len(tt.want) {
+				t.Errorf("Expected %d arguments, got %v", len(tt.want), args)
+			}
+		})
+	}
+}
+
+func TestExecuteCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cf := NewCommandFileInfo(config.Config{AllowedRoots: []config.AllowedRoot{{Path: dir}}}, nil, nil)
+	cf.registry = NewRegistry()
+	if err := cf.registry.Register(Command{Name: "ECHO", Handler: echo, Params: []Param{{Name: "path", Type: ParamPath, Required: true}}}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	if _, err := cf.ExecuteCommand(context.Background(), "ECHO", map[string]string{"path": file}); err != nil {
+		t.Errorf("Expected the command to run, got %v", err)
+	}
+
+	var accessErr *AccessError
+	if _, err := cf.ExecuteCommand(context.Background(), "ECHO", map[string]string{"path": os.TempDir()}); !errors.As(err, &accessErr) {
+		t.Errorf("Expected a path outside the roots to be denied, got %v", err)
+	}
+
+	if _, err := cf.ExecuteCommand(context.Background(), "NOPE", nil); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("Expected an unknown command error, got %v", err)
+	}
+}
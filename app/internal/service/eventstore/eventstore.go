@@ -0,0 +1,335 @@
+package eventstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	eventsBucket = []byte("events")
+	scansBucket  = []byte("scans")
+	latestBucket = []byte("latest")
+)
+
+// Record - a stored change event with its id, ids increase in the order events were added
+type Record struct {
+	ID uint64 `json:"id"`
+	snapshot.Event
+}
+
+// ScanRecord - a stored scan result of a path. A tombstone marks the path deleted or renamed away,
+// it has Removed set, RenamedTo for a rename, and no file info besides the path.
+type ScanRecord struct {
+	ID        uint64             `json:"id"`
+	Time      time.Time          `json:"scan_time"`
+	Removed   snapshot.EventType `json:"removed,omitempty"`
+	RenamedTo string             `json:"renamed_to,omitempty"`
+	filetrack.FileInfo
+}
+
+// Filter - which events to return, zero values match everything
+type Filter struct {
+	PathPrefix string
+	Types      []snapshot.EventType
+	Since      time.Time
+	Until      time.Time
+	After      uint64
+	Limit      int
+}
+
+// Options - retention of the store, MaxScans is the most scans kept per path and MaxEvents the most events kept,
+// 0 keeps all of them
+type Options struct {
+	MaxScans  int
+	MaxEvents int
+}
+
+// Store - bbolt file holding every change event and the scan results of each path
+type Store struct {
+	db      *bolt.DB
+	options Options
+}
+
+// Open - open or create the store file
+func Open(path string, options Options) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening event store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{eventsBucket, scansBucket, latestBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating event store buckets: %w", err)
+	}
+
+	return &Store{db: db, options: options}, nil
+}
+
+// Close - close the store file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// AddEvent - append a change event, returning the stored record with its id. The oldest events over MaxEvents are removed.
+func (s *Store) AddEvent(event snapshot.Event) (Record, error) {
+	var record Record
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		record = Record{ID: id, Event: event}
+		js, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put(itob(id), js); err != nil {
+			return err
+		}
+		return s.trimEvents(bucket, id)
+	})
+
+	return record, err
+}
+
+// trimEvents - remove the events that are more than MaxEvents older than the event with id last
+func (s *Store) trimEvents(bucket *bolt.Bucket, last uint64) error {
+	if s.options.MaxEvents <= 0 || last <= uint64(s.options.MaxEvents) {
+		return nil
+	}
+
+	oldest := last - uint64(s.options.MaxEvents) + 1
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) < oldest; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddScan - store a scan result of a path, results equal to the last stored one are skipped
+// so the scans of a path are its state transitions. The atime is left out of the comparison since reading
+// the file changes it. The oldest scans of the path over MaxScans are removed.
+func (s *Store) AddScan(info filetrack.FileInfo) error {
+	latest, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	var unchanged bool
+	err = s.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(latestBucket).Get([]byte(info.Path))
+		if stored == nil {
+			return nil
+		}
+
+		var previous filetrack.FileInfo
+		if err := json.Unmarshal(stored, &previous); err != nil {
+			return err
+		}
+		previous.AccessedTime = info.AccessedTime
+		unchanged = previous == info
+		return nil
+	})
+	if err != nil || unchanged {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(scansBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		js, err := json.Marshal(ScanRecord{ID: id, Time: time.Now(), FileInfo: info})
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put(scanKey(info.Path, id), js); err != nil {
+			return err
+		}
+		if err := s.compact(bucket, info.Path); err != nil {
+			return err
+		}
+		return tx.Bucket(latestBucket).Put([]byte(info.Path), latest)
+	})
+}
+
+// compact - remove the oldest scans of a path until MaxScans are left
+func (s *Store) compact(bucket *bolt.Bucket, path string) error {
+	if s.options.MaxScans <= 0 {
+		return nil
+	}
+
+	prefix := append([]byte(path), 0)
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	for len(keys) > s.options.MaxScans {
+		if err := bucket.Delete(keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+
+	return nil
+}
+
+// Remove - forget the last state of a deleted or renamed path and end its scans with a tombstone,
+// so a file created again at the path starts a new run of versions. A path without a last state is left as it is.
+func (s *Store) Remove(path string, removed snapshot.EventType, renamedTo string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		latest := tx.Bucket(latestBucket)
+		if latest.Get([]byte(path)) == nil {
+			return nil
+		}
+
+		bucket := tx.Bucket(scansBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		tombstone := ScanRecord{ID: id, Time: time.Now(), Removed: removed, RenamedTo: renamedTo, FileInfo: filetrack.FileInfo{Path: path}}
+		js, err := json.Marshal(tombstone)
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put(scanKey(path, id), js); err != nil {
+			return err
+		}
+		return latest.Delete([]byte(path))
+	})
+}
+
+// Latest - last stored state of every path, used to restore the snapshot after a restart
+func (s *Store) Latest() ([]filetrack.FileInfo, error) {
+	var infos []filetrack.FileInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(latestBucket).ForEach(func(_, v []byte) error {
+			var info filetrack.FileInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+
+	return infos, err
+}
+
+// Scans - every stored scan result of a path, oldest first
+func (s *Store) Scans(path string) ([]ScanRecord, error) {
+	var scans []ScanRecord
+	prefix := append([]byte(path), 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(scansBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var scan ScanRecord
+			if err := json.Unmarshal(v, &scan); err != nil {
+				return err
+			}
+			scans = append(scans, scan)
+		}
+		return nil
+	})
+
+	return scans, err
+}
+
+// Events - events matching the filter in id order, starting after filter.After
+func (s *Store) Events(filter Filter) ([]Record, error) {
+	records := []Record{}
+	if filter.After == math.MaxUint64 {
+		return records, nil
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Seek(itob(filter.After + 1)); k != nil; k, v = c.Next() {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+
+			if !filter.match(record) {
+				continue
+			}
+
+			records = append(records, record)
+			if filter.Limit > 0 && len(records) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+
+	return records, err
+}
+
+// match - check a record against every filter that is set
+func (f Filter) match(record Record) bool {
+	if f.PathPrefix != "" && !strings.HasPrefix(record.Path, f.PathPrefix) && !strings.HasPrefix(record.OldPath, f.PathPrefix) {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Time.After(f.Until) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, eventType := range f.Types {
+		if record.Type == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// itob - big endian key so bolt keeps records in id order
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// scanKey - path, a zero byte then the id so the scans of a path sort together
+func scanKey(path string, id uint64) []byte {
+	return append(append([]byte(path), 0), itob(id)...)
+}
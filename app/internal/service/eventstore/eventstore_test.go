@@ -0,0 +1,179 @@
+package eventstore
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/snapshot"
+)
+
+func TestEvents(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"), Options{})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Now()
+	events := []snapshot.Event{
+		{Type: snapshot.Created, Time: start, FileInfo: filetrack.FileInfo{Path: "/a/one.txt"}},
+		{Type: snapshot.ContentModified, Time: start.Add(time.Minute), FileInfo: filetrack.FileInfo{Path: "/a/one.txt"}},
+		{Type: snapshot.Created, Time: start.Add(2 * time.Minute), FileInfo: filetrack.FileInfo{Path: "/b/two.txt"}},
+		{Type: snapshot.Deleted, Time: start.Add(3 * time.Minute), FileInfo: filetrack.FileInfo{Path: "/a/one.txt"}},
+	}
+	for _, event := range events {
+		if _, err := store.AddEvent(event); err != nil {
+			t.Fatalf("AddEvent returned an error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []uint64
+	}{
+		{"all", Filter{}, []uint64{1, 2, 3, 4}},
+		{"path prefix", Filter{PathPrefix: "/a/"}, []uint64{1, 2, 4}},
+		{"type", Filter{Types: []snapshot.EventType{snapshot.Created}}, []uint64{1, 3}},
+		{"time range", Filter{Since: start.Add(30 * time.Second), Until: start.Add(150 * time.Second)}, []uint64{2, 3}},
+		{"first page", Filter{Limit: 2}, []uint64{1, 2}},
+		{"next page", Filter{After: 2, Limit: 2}, []uint64{3, 4}},
+		{"after the last id", Filter{After: math.MaxUint64}, nil},
+	}
+
+	for _, test := range tests {
+		records, err := store.Events(test.filter)
+		if err != nil {
+			t.Fatalf("%s: Events returned an error: %v", test.name, err)
+		}
+
+		var ids []uint64
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		if len(ids) != len(test.expected) {
+			t.Errorf("%s: got ids %v; want %v", test.name, ids, test.expected)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != test.expected[i] {
+				t.Errorf("%s: got ids %v; want %v", test.name, ids, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestScans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+
+	info := filetrack.FileInfo{Path: "/a/one.txt", FileSize: "1"}
+	for _, size := range []string{"1", "1", "2", "2", "3"} {
+		info.FileSize = size
+		if err := store.AddScan(info); err != nil {
+			t.Fatalf("AddScan returned an error: %v", err)
+		}
+	}
+	if err := store.AddScan(filetrack.FileInfo{Path: "/a/one.txt.bak"}); err != nil {
+		t.Fatalf("AddScan returned an error: %v", err)
+	}
+
+	// reopen to check the history survives a restart
+	store.Close()
+	store, err = Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer store.Close()
+
+	scans, err := store.Scans("/a/one.txt")
+	if err != nil {
+		t.Fatalf("Scans returned an error: %v", err)
+	}
+	if len(scans) != 3 || scans[0].FileSize != "1" || scans[2].FileSize != "3" {
+		t.Errorf("Expected the 3 distinct states of the path, got %+v", scans)
+	}
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatalf("Latest returned an error: %v", err)
+	}
+	if len(latest) != 2 {
+		t.Errorf("Expected the latest state of 2 paths, got %d", len(latest))
+	}
+
+	// a deleted path ends with a tombstone, the same state added again after it is a new scan
+	for i := 0; i < 2; i++ {
+		if err := store.Remove("/a/one.txt", snapshot.Deleted, ""); err != nil {
+			t.Fatalf("Remove returned an error: %v", err)
+		}
+	}
+	if err := store.AddScan(info); err != nil {
+		t.Fatalf("AddScan returned an error: %v", err)
+	}
+	scans, _ = store.Scans("/a/one.txt")
+	if len(scans) != 5 || scans[3].Removed != snapshot.Deleted || scans[4].Removed != "" || scans[4].FileSize != "3" {
+		t.Errorf("Expected one tombstone between the states, got %+v", scans)
+	}
+}
+
+func TestScansRetention(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"), Options{MaxScans: 2})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer store.Close()
+
+	// reading the file only changes the atime, which is not a new state
+	info := filetrack.FileInfo{Path: "/a/one.txt", FileSize: "1", AccessedTime: "100"}
+	for _, atime := range []string{"100", "200", "300"} {
+		info.AccessedTime = atime
+		if err := store.AddScan(info); err != nil {
+			t.Fatalf("AddScan returned an error: %v", err)
+		}
+	}
+	if scans, _ := store.Scans("/a/one.txt"); len(scans) != 1 {
+		t.Errorf("Expected the atime changes to be skipped, got %d scans", len(scans))
+	}
+
+	for _, size := range []string{"2", "3", "4"} {
+		info.FileSize = size
+		if err := store.AddScan(info); err != nil {
+			t.Fatalf("AddScan returned an error: %v", err)
+		}
+	}
+	scans, _ := store.Scans("/a/one.txt")
+	if len(scans) != 2 || scans[0].FileSize != "3" || scans[1].FileSize != "4" {
+		t.Errorf("Expected the 2 newest scans to be kept, got %+v", scans)
+	}
+}
+
+func TestEventsRetention(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"), Options{MaxEvents: 2})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := store.AddEvent(snapshot.Event{Type: snapshot.Created, FileInfo: filetrack.FileInfo{Path: "/a/one.txt"}}); err != nil {
+			t.Fatalf("AddEvent returned an error: %v", err)
+		}
+	}
+
+	// ids keep counting from the newest event, an after older than the oldest kept one starts from it
+	records, err := store.Events(Filter{After: 1})
+	if err != nil {
+		t.Fatalf("Events returned an error: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != 4 || records[1].ID != 5 {
+		t.Errorf("Expected the 2 newest events to be kept, got %+v", records)
+	}
+}
@@ -1,9 +1,14 @@
 package filetrack
 
 import (
-	"encoding/json"
-	"fmt"
-	"os/exec"
+	"context"
+	"github.com/thespider911/filetrackermodification/app/internal/osquery"
+)
+
+// tracker backends selectable with the tracker_backend config key
+const (
+	BackendOsquery = "osquery"
+	BackendNative  = "native"
 )
 
 // FileInfo - file info struct
@@ -18,6 +23,8 @@ type FileInfo struct {
 	FileSize     string `json:"size"`
 	FileType     string `json:"type"`
 	Permission   string `json:"mode"`
+	Inode        string `json:"inode"`
+	Hash         string `json:"hash,omitempty"`
 }
 
 // FileTracker interface defines the contract for file tracking operations
@@ -25,25 +32,18 @@ type FileTracker interface {
 	FetchFilesInfo(filePath string) (*FileInfo, error)
 }
 
-// OsqueryFileTracker implements FileTracker using osquery
-type OsqueryFileTracker struct{}
+// OsqueryFileTracker implements FileTracker using osquery, a nil Client runs osqueryi
+type OsqueryFileTracker struct {
+	Client *osquery.Client
+}
 
 // FetchFilesInfo - get files info from querying the path returning fileInfo
 func (ft OsqueryFileTracker) FetchFilesInfo(filePath string) (*FileInfo, error) {
 	var fileInfos []FileInfo
 
-	// osquery query and command run
-	query := fmt.Sprintf("SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode FROM file WHERE path = '%s';", filePath)
-	cmd := exec.Command("osqueryi", "--json", query)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// decode the output
-	err = json.Unmarshal(output, &fileInfos)
-	if err != nil {
+	// osquery query, the path is bound as a quoted literal, the client times the call out
+	query := "SELECT uid, path, directory, filename, mtime, atime, ctime, size, type, mode, inode FROM file WHERE path = ?;"
+	if err := ft.Client.QueryInto(context.Background(), &fileInfos, query, filePath); err != nil {
 		return nil, err
 	}
 
@@ -55,7 +55,20 @@ func (ft OsqueryFileTracker) FetchFilesInfo(filePath string) (*FileInfo, error)
 	return nil, nil
 }
 
-// NewFileTracker creates a new instance of OsqueryFileTracker
-func NewFileTracker() FileTracker {
-	return OsqueryFileTracker{}
+// NewFileTracker creates a new FileTracker for the given backend, defaulting to osquery with the client,
+// that also hashes file contents when a hash algorithm is given
+func NewFileTracker(backend, hashAlgorithm string, client *osquery.Client) FileTracker {
+	var tracker FileTracker
+	switch backend {
+	case BackendNative:
+		tracker = NativeFileTracker{}
+	default:
+		tracker = OsqueryFileTracker{Client: client}
+	}
+
+	if hashAlgorithm != "" {
+		return HashingFileTracker{FileTracker: tracker, Algorithm: hashAlgorithm}
+	}
+
+	return tracker
 }
@@ -0,0 +1,69 @@
+package filetrack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// content hash algorithms selectable with the hash_algorithm config key
+const (
+	HashSHA256  = "sha256"
+	HashBLAKE2b = "blake2b"
+)
+
+// HashFile - hex encoded hash of the file contents
+func HashFile(filePath, algorithm string) (string, error) {
+	var h hash.Hash
+	switch algorithm {
+	case HashSHA256:
+		h = sha256.New()
+	case HashBLAKE2b:
+		h, _ = blake2b.New256(nil)
+	default:
+		return "", fmt.Errorf("unknown hash algorithm: %s", algorithm)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", filePath, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashingFileTracker wraps a FileTracker and adds the content hash of regular files
+type HashingFileTracker struct {
+	FileTracker
+	Algorithm string
+}
+
+// FetchFilesInfo - get files info from the wrapped tracker then hash the contents,
+// a file removed between the two is reported as gone like the wrapped tracker does
+func (ft HashingFileTracker) FetchFilesInfo(filePath string) (*FileInfo, error) {
+	fileInfo, err := ft.FileTracker.FetchFilesInfo(filePath)
+	if err != nil || fileInfo == nil || fileInfo.FileType != "regular" {
+		return fileInfo, err
+	}
+
+	fileInfo.Hash, err = HashFile(filePath, ft.Algorithm)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo, nil
+}
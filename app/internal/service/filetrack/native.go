@@ -0,0 +1,78 @@
+package filetrack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// NativeFileTracker implements FileTracker using os.Lstat, without spawning osquery
+type NativeFileTracker struct{}
+
+// FetchFilesInfo - stat the path and fill the same fields the osquery file table returns
+func (ft NativeFileTracker) FetchFilesInfo(filePath string) (*FileInfo, error) {
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		// osquery returns no rows for a missing path, do the same here
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	fileInfo := &FileInfo{
+		Path:         filePath,
+		Directory:    filepath.Dir(filePath),
+		Filename:     filepath.Base(filePath),
+		ModifiedTime: strconv.FormatInt(info.ModTime().Unix(), 10),
+		AccessedTime: strconv.FormatInt(info.ModTime().Unix(), 10),
+		ChangedTime:  strconv.FormatInt(info.ModTime().Unix(), 10),
+		FileSize:     strconv.FormatInt(info.Size(), 10),
+		FileType:     fileType(info.Mode()),
+		Permission:   fileMode(info.Mode()),
+	}
+
+	// uid, atime and ctime come from the platform specific stat data
+	fillSysInfo(fileInfo, info)
+
+	return fileInfo, nil
+}
+
+// fileType - map the file mode to the type names used by the osquery file table
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "regular"
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character"
+	case mode&os.ModeDevice != 0:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// fileMode - format the permission bits as the octal string osquery returns e.g. 0644
+func fileMode(mode os.FileMode) string {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 01000
+	}
+
+	return fmt.Sprintf("%04o", perm)
+}
@@ -0,0 +1,20 @@
+package filetrack
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fillSysInfo - fill uid, inode, atime and ctime from syscall.Stat_t
+func fillSysInfo(fileInfo *FileInfo, info os.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	fileInfo.Uid = strconv.FormatUint(uint64(stat.Uid), 10)
+	fileInfo.Inode = strconv.FormatUint(uint64(stat.Ino), 10)
+	fileInfo.AccessedTime = strconv.FormatInt(int64(stat.Atim.Sec), 10)
+	fileInfo.ChangedTime = strconv.FormatInt(int64(stat.Ctim.Sec), 10)
+}
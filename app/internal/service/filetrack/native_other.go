@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package filetrack
+
+import "os"
+
+// fillSysInfo - no portable stat data on this platform, atime and ctime keep the mtime
+func fillSysInfo(fileInfo *FileInfo, info os.FileInfo) {}
@@ -0,0 +1,121 @@
+package filetrack
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNativeFetchFilesInfo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0640); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatalf("Failed to chmod test file: %v", err)
+	}
+
+	info, err := NativeFileTracker{}.FetchFilesInfo(path)
+	if err != nil {
+		t.Fatalf("FetchFilesInfo returned an error: %v", err)
+	}
+
+	stat, _ := os.Lstat(path)
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"path", info.Path, path},
+		{"directory", info.Directory, dir},
+		{"filename", info.Filename, "test.txt"},
+		{"size", info.FileSize, "5"},
+		{"type", info.FileType, "regular"},
+		{"mode", info.Permission, "0640"},
+		{"mtime", info.ModifiedTime, strconv.FormatInt(stat.ModTime().Unix(), 10)},
+	}
+
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%s = %s; want %s", test.name, test.got, test.expected)
+		}
+	}
+
+	if info.Uid == "" || info.AccessedTime == "" || info.ChangedTime == "" {
+		t.Errorf("Expected uid, atime and ctime to be set, got %+v", info)
+	}
+}
+
+func TestNativeFetchFilesInfoMissing(t *testing.T) {
+	info, err := NativeFileTracker{}.FetchFilesInfo(filepath.Join(t.TempDir(), "missing.txt"))
+	if err != nil {
+		t.Errorf("Expected no error for a missing file, got %v", err)
+	}
+	if info != nil {
+		t.Errorf("Expected nil info for a missing file, got %+v", info)
+	}
+}
+
+func TestNewFileTracker(t *testing.T) {
+	if _, ok := NewFileTracker(BackendNative, "", nil).(NativeFileTracker); !ok {
+		t.Error("Expected NativeFileTracker for the native backend")
+	}
+	if _, ok := NewFileTracker(BackendOsquery, "", nil).(OsqueryFileTracker); !ok {
+		t.Error("Expected OsqueryFileTracker for the osquery backend")
+	}
+	if _, ok := NewFileTracker(BackendNative, HashSHA256, nil).(HashingFileTracker); !ok {
+		t.Error("Expected HashingFileTracker when a hash algorithm is set")
+	}
+}
+
+func TestHashingFetchFilesInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		algorithm string
+		expected  string
+	}{
+		{HashSHA256, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{HashBLAKE2b, "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf"},
+	}
+
+	for _, test := range tests {
+		tracker := HashingFileTracker{FileTracker: NativeFileTracker{}, Algorithm: test.algorithm}
+		info, err := tracker.FetchFilesInfo(path)
+		if err != nil {
+			t.Fatalf("FetchFilesInfo(%s) returned an error: %v", test.algorithm, err)
+		}
+		if info.Hash != test.expected {
+			t.Errorf("%s hash = %s; want %s", test.algorithm, info.Hash, test.expected)
+		}
+	}
+}
+
+// removingTracker - removes the file right after its info was fetched, as if it was deleted before it is hashed
+type removingTracker struct {
+	NativeFileTracker
+}
+
+func (ft removingTracker) FetchFilesInfo(filePath string) (*FileInfo, error) {
+	info, err := ft.NativeFileTracker.FetchFilesInfo(filePath)
+	os.Remove(filePath)
+	return info, err
+}
+
+func TestHashingFetchFilesInfoRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tracker := HashingFileTracker{FileTracker: removingTracker{}, Algorithm: HashSHA256}
+	info, err := tracker.FetchFilesInfo(path)
+	if err != nil || info != nil {
+		t.Errorf("Expected a file removed before it is hashed to be reported as gone, got %+v (%v)", info, err)
+	}
+}
@@ -0,0 +1,19 @@
+package filetrack
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fillSysInfo - fill atime and ctime from the win32 attributes, windows has no uid or status change time
+func fillSysInfo(fileInfo *FileInfo, info os.FileInfo) {
+	attr, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return
+	}
+
+	fileInfo.Uid = "0"
+	fileInfo.AccessedTime = strconv.FormatInt(attr.LastAccessTime.Nanoseconds()/1e9, 10)
+	fileInfo.ChangedTime = strconv.FormatInt(attr.LastWriteTime.Nanoseconds()/1e9, 10)
+}
@@ -0,0 +1,177 @@
+package integrity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+	"github.com/thespider911/filetrackermodification/app/internal/service/scan"
+)
+
+var ErrNoBaseline = errors.New("integrity: no baseline has been created")
+
+// violation reasons
+const (
+	ReasonModified = "modified"
+	ReasonMissing  = "missing"
+	ReasonAdded    = "added"
+)
+
+// Violation - a file that does not match the approved baseline
+type Violation struct {
+	Path     string `json:"path"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected_hash,omitempty"`
+	Actual   string `json:"actual_hash,omitempty"`
+}
+
+// Baseline - approved content hashes of every file the watch targets track
+type Baseline struct {
+	Algorithm   string            `json:"algorithm"`
+	Directories []string          `json:"directories"`
+	Created     time.Time         `json:"created"`
+	Files       map[string]string `json:"files"`
+
+	// last reported hash per path so a violation is only reported once
+	mu       sync.Mutex
+	reported map[string]string
+}
+
+// Create - hash every regular file the targets track, with the same filters as the scans
+func Create(targets []config.WatchTarget, algorithm string) (*Baseline, error) {
+	files, err := hashTargets(targets, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		dirs = append(dirs, target.Path)
+	}
+
+	return &Baseline{
+		Algorithm:   algorithm,
+		Directories: dirs,
+		Created:     time.Now(),
+		Files:       files,
+	}, nil
+}
+
+// Load - read a baseline saved with Save
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoBaseline
+		}
+		return nil, fmt.Errorf("error reading baseline: %w", err)
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("error decoding baseline: %w", err)
+	}
+
+	return &baseline, nil
+}
+
+// Save - write the baseline as json, replacing the file atomically
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("error writing baseline: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Verify - hash the files the targets track again and list every file that diverges from the baseline
+func (b *Baseline) Verify(targets []config.WatchTarget) ([]Violation, error) {
+	current, err := hashTargets(targets, b.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []Violation
+	for path, expected := range b.Files {
+		actual, ok := current[path]
+		switch {
+		case !ok:
+			violations = append(violations, Violation{Path: path, Reason: ReasonMissing, Expected: expected})
+		case actual != expected:
+			violations = append(violations, Violation{Path: path, Reason: ReasonModified, Expected: expected, Actual: actual})
+		}
+	}
+	for path, actual := range current {
+		if _, ok := b.Files[path]; !ok {
+			violations = append(violations, Violation{Path: path, Reason: ReasonAdded, Actual: actual})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations, nil
+}
+
+// Check - compare the current hash of one file, returning a violation the first time it diverges
+func (b *Baseline) Check(path, hash string) *Violation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	expected, ok := b.Files[path]
+	if ok && expected == hash {
+		delete(b.reported, path)
+		return nil
+	}
+
+	if b.reported == nil {
+		b.reported = make(map[string]string)
+	}
+	if b.reported[path] == hash {
+		return nil
+	}
+	b.reported[path] = hash
+
+	if !ok {
+		return &Violation{Path: path, Reason: ReasonAdded, Actual: hash}
+	}
+	return &Violation{Path: path, Reason: ReasonModified, Expected: expected, Actual: hash}
+}
+
+// hashTargets - hash of every regular file the targets track keyed by path, a file under nested targets
+// follows the filters of the deepest one as it does in the scans
+func hashTargets(targets []config.WatchTarget, algorithm string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, target := range targets {
+		err := scan.Walk(target, target.Path, func(path string, info os.FileInfo) error {
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			if owner, _ := scan.Owner(targets, path); owner.Path != target.Path {
+				return nil
+			}
+
+			hash, err := filetrack.HashFile(path, algorithm)
+			if err != nil {
+				return err
+			}
+			files[path] = hash
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error hashing %s: %w", target.Path, err)
+		}
+	}
+
+	return files, nil
+}
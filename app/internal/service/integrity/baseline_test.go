@@ -0,0 +1,82 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+)
+
+func TestBaselineVerify(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	kept := write("kept.txt", "kept")
+	changed := write("changed.txt", "before")
+	removed := write("removed.txt", "removed")
+	write("build.log", "before")
+
+	// the baseline covers the files the target tracks, not every file under it
+	targets := []config.WatchTarget{{Path: dir, Exclude: []string{"*.log"}}}
+	baseline, err := Create(targets, filetrack.HashSHA256)
+	if err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+
+	// save and load it back the way the baseline command does
+	baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+	if err := baseline.Save(baselineFile); err != nil {
+		t.Fatalf("Save returned an error: %v", err)
+	}
+	baseline, err = Load(baselineFile)
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	// same size contents so only the hash can tell
+	write("changed.txt", "after!")
+	added := write("added.txt", "added")
+	write("build.log", "after")
+	write("new.log", "new")
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	violations, err := baseline.Verify(targets)
+	if err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+
+	expected := []Violation{
+		{Path: added, Reason: ReasonAdded},
+		{Path: changed, Reason: ReasonModified},
+		{Path: removed, Reason: ReasonMissing},
+	}
+	if len(violations) != len(expected) {
+		t.Fatalf("Expected %d violations, got %v", len(expected), violations)
+	}
+	for i, violation := range violations {
+		if violation.Path != expected[i].Path || violation.Reason != expected[i].Reason {
+			t.Errorf("violation %d = %s %s; want %s %s", i, violation.Path, violation.Reason, expected[i].Path, expected[i].Reason)
+		}
+	}
+
+	// a check against an approved hash passes, a diverged hash is reported only once
+	if v := baseline.Check(kept, baseline.Files[kept]); v != nil {
+		t.Errorf("Expected no violation for an unchanged file, got %v", v)
+	}
+	if v := baseline.Check(changed, "other"); v == nil || v.Reason != ReasonModified {
+		t.Errorf("Expected a modified violation, got %v", v)
+	}
+	if v := baseline.Check(changed, "other"); v != nil {
+		t.Errorf("Expected the same violation not to be reported twice, got %v", v)
+	}
+}
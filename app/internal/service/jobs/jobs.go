@@ -0,0 +1,296 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// job states, a job ends succeeded, failed or cancelled
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	ErrNotFound  = errors.New("jobs: no such job")
+	ErrQueueFull = errors.New("jobs: the job queue is full")
+	ErrFinished  = errors.New("jobs: the job has already finished")
+	ErrClosed    = errors.New("jobs: the job manager is closed")
+)
+
+// RunFunc - runs the command of a job, reporting its progress as done of total steps
+type RunFunc func(ctx context.Context, command string, params map[string]string, progress func(done, total int)) (interface{}, error)
+
+// Progress - steps of a running job, total is 0 while unknown
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Job - a command run in the background and its outcome, owner is the caller that created it
+type Job struct {
+	ID       string            `json:"id"`
+	Owner    string            `json:"owner,omitempty"`
+	Command  string            `json:"command"`
+	Params   map[string]string `json:"params"`
+	Status   string            `json:"status"`
+	Progress Progress          `json:"progress"`
+	Result   interface{}       `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Created  time.Time         `json:"created"`
+	Started  *time.Time        `json:"started,omitempty"`
+	Finished *time.Time        `json:"finished,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// Options - worker count, queued job limit and how long finished jobs are kept
+type Options struct {
+	Workers   int
+	QueueSize int
+	Retention time.Duration
+}
+
+// Manager - queues jobs and runs them on a fixed number of workers
+type Manager struct {
+	run     RunFunc
+	options Options
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	// queue holds the queued jobs oldest first, ready is signalled when one is added or the manager closes
+	mu     sync.Mutex
+	ready  *sync.Cond
+	queue  []*Job
+	jobs   map[string]*Job
+	closed bool
+}
+
+// NewManager - start the workers running jobs with run
+func NewManager(run RunFunc, options Options) *Manager {
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		run:     run,
+		options: options,
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*Job),
+	}
+	m.ready = sync.NewCond(&m.mu)
+
+	for i := 0; i < options.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+
+	return m
+}
+
+// Submit - queue a command for owner, returns the queued job
+func (m *Manager) Submit(owner, command string, params map[string]string) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return Job{}, ErrClosed
+	}
+	m.prune()
+
+	if len(m.queue) >= m.options.QueueSize {
+		return Job{}, ErrQueueFull
+	}
+
+	job := &Job{ID: id, Owner: owner, Command: command, Params: params, Status: StatusQueued, Created: time.Now()}
+	m.queue = append(m.queue, job)
+	m.jobs[id] = job
+	m.ready.Signal()
+
+	return job.snapshot(), nil
+}
+
+// Get - the job by id, finished jobs are kept for the retention period
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	return job.snapshot(), nil
+}
+
+// List - every kept job, the newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune()
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, job.snapshot())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+
+	return list
+}
+
+// Cancel - cancel a queued job at once, freeing its place in the queue, a running one is cancelled
+// through its context and ends cancelled when its command returns
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	switch job.Status {
+	case StatusQueued:
+		now := time.Now()
+		job.Status, job.Finished = StatusCancelled, &now
+		m.dequeue(job)
+	case StatusRunning:
+		job.cancel()
+	default:
+		return job.snapshot(), ErrFinished
+	}
+
+	return job.snapshot(), nil
+}
+
+// Close - cancel the running jobs and wait for the workers to stop, queued jobs are dropped
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.ready.Broadcast()
+	m.mu.Unlock()
+
+	m.stop()
+	m.wg.Wait()
+}
+
+// worker - run queued jobs until the manager is closed
+func (m *Manager) worker() {
+	defer m.wg.Done()
+
+	for {
+		job, ok := m.next()
+		if !ok {
+			return
+		}
+		m.runJob(job)
+	}
+}
+
+// next - wait for the oldest queued job, false once the manager is closed
+func (m *Manager) next() (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.queue) == 0 && !m.closed {
+		m.ready.Wait()
+	}
+	if m.closed {
+		return nil, false
+	}
+
+	job := m.queue[0]
+	m.queue[0] = nil
+	m.queue = m.queue[1:]
+
+	return job, true
+}
+
+// dequeue - take a queued job out of the queue, the caller holds mu
+func (m *Manager) dequeue(job *Job) {
+	for i, queued := range m.queue {
+		if queued == job {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// runJob - run the job unless it was cancelled while queued
+func (m *Manager) runJob(job *Job) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	m.mu.Lock()
+	if job.Status != StatusQueued {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status, job.Started, job.cancel = StatusRunning, &now, cancel
+	m.mu.Unlock()
+
+	progress := func(done, total int) {
+		m.mu.Lock()
+		job.Progress = Progress{Done: done, Total: total}
+		m.mu.Unlock()
+	}
+
+	result, err := m.run(ctx, job.Command, job.Params, progress)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := time.Now()
+	job.Finished = &finished
+	switch {
+	case ctx.Err() != nil:
+		job.Status = StatusCancelled
+	case err != nil:
+		job.Status, job.Error = StatusFailed, err.Error()
+	default:
+		job.Status, job.Result = StatusSucceeded, result
+	}
+}
+
+// prune - drop the jobs finished longer ago than the retention period, the caller holds mu
+func (m *Manager) prune() {
+	cutoff := time.Now().Add(-m.options.Retention)
+	for id, job := range m.jobs {
+		if job.Finished != nil && job.Finished.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// snapshot - copy of the job safe to use without holding mu
+func (j *Job) snapshot() Job {
+	job := *j
+	job.cancel = nil
+
+	return job
+}
+
+// newID - random job id
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
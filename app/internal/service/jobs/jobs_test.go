@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor - poll the job until it has the status
+func waitFor(t *testing.T, m *Manager, id, status string) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected job %s to be %s, still %s", id, status, job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManager(t *testing.T) {
+	release := make(chan struct{})
+	run := func(ctx context.Context, command string, params map[string]string, progress func(done, total int)) (interface{}, error) {
+		switch command {
+		case "FAIL":
+			return nil, errors.New("boom")
+		case "BLOCK":
+			progress(1, 2)
+			select {
+			case <-release:
+				return "released", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		default:
+			return params["path"], nil
+		}
+	}
+
+	m := NewManager(run, Options{Workers: 1, QueueSize: 1, Retention: time.Hour})
+	defer m.Close()
+
+	job, err := m.Submit("admin", "ECHO", map[string]string{"path": "/tmp/a.txt"})
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	if job.Status != StatusQueued || job.ID == "" {
+		t.Errorf("Expected a queued job with an id, got %+v", job)
+	}
+	if job = waitFor(t, m, job.ID, StatusSucceeded); job.Result != "/tmp/a.txt" || job.Finished == nil {
+		t.Errorf("Expected the result to be kept, got %+v", job)
+	}
+
+	failed, _ := m.Submit("admin", "FAIL", nil)
+	if job = waitFor(t, m, failed.ID, StatusFailed); job.Error != "boom" {
+		t.Errorf("Expected the error to be kept, got %q", job.Error)
+	}
+
+	// the one worker is busy with the blocked job, the next fills the queue
+	blocked, _ := m.Submit("admin", "BLOCK", nil)
+	waitFor(t, m, blocked.ID, StatusRunning)
+	queued, _ := m.Submit("admin", "ECHO", nil)
+	if _, err := m.Submit("admin", "ECHO", nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected a full queue, got %v", err)
+	}
+
+	if job, err = m.Cancel(queued.ID); err != nil || job.Status != StatusCancelled {
+		t.Errorf("Expected the queued job to be cancelled at once, got %s (%v)", job.Status, err)
+	}
+
+	// the cancelled job frees its place in the queue
+	next, err := m.Submit("admin", "ECHO", nil)
+	if err != nil {
+		t.Errorf("Expected the cancelled job to leave the queue, got %v", err)
+	}
+	if job, _ = m.Get(blocked.ID); job.Progress != (Progress{Done: 1, Total: 2}) {
+		t.Errorf("Expected the reported progress, got %+v", job.Progress)
+	}
+	if _, err := m.Cancel(blocked.ID); err != nil {
+		t.Fatalf("Failed to cancel the running job: %v", err)
+	}
+	waitFor(t, m, blocked.ID, StatusCancelled)
+	waitFor(t, m, next.ID, StatusSucceeded)
+	if job, _ = m.Get(queued.ID); job.Started != nil {
+		t.Errorf("Expected the cancelled job never to run, got %+v", job)
+	}
+
+	if _, err := m.Cancel(failed.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected a finished job not to be cancelled, got %v", err)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an unknown job not to be found, got %v", err)
+	}
+	if list := m.List(); len(list) != 5 {
+		t.Errorf("Expected 5 jobs, got %d", len(list))
+	}
+}
+
+func TestManagerRetention(t *testing.T) {
+	run := func(ctx context.Context, command string, params map[string]string, progress func(done, total int)) (interface{}, error) {
+		return nil, nil
+	}
+
+	m := NewManager(run, Options{Workers: 1, QueueSize: 1, Retention: 20 * time.Millisecond})
+	defer m.Close()
+
+	job, _ := m.Submit("admin", "ECHO", nil)
+	waitFor(t, m, job.ID, StatusSucceeded)
+
+	time.Sleep(40 * time.Millisecond)
+	if _, err := m.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the finished job to be dropped after the retention period, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	pendingBucket = []byte("pending")
+	deadBucket    = []byte("dead")
+)
+
+// Item - a payload waiting to be delivered to the api
+type Item struct {
+	ID          uint64          `json:"id"`
+	Payload     json.RawMessage `json:"payload"`
+	Endpoint    string          `json:"endpoint,omitempty"`
+	Created     time.Time       `json:"created"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// Stats - outbox state reported on /health
+type Stats struct {
+	Depth       int           `json:"depth"`
+	OldestAge   time.Duration `json:"-"`
+	DeadLetters int           `json:"dead_letters"`
+}
+
+// Options - retry policy, the delay doubles after every failed attempt up to MaxDelay
+type Options struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Outbox - bbolt backed queue of undelivered payloads that survives restarts
+type Outbox struct {
+	db      *bolt.DB
+	options Options
+}
+
+// Open - open or create the outbox file
+func Open(path string, options Options) (*Outbox, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening outbox %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{pendingBucket, deadBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating outbox buckets: %w", err)
+	}
+
+	return &Outbox{db: db, options: options}, nil
+}
+
+// Close - close the outbox file
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+// Enqueue - add a payload to be delivered to endpoint as soon as possible
+func (o *Outbox) Enqueue(payload []byte, endpoint string) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pendingBucket)
+
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return putItem(bucket, Item{ID: id, Payload: payload, Endpoint: endpoint, Created: now, NextAttempt: now})
+	})
+}
+
+// Due - up to limit items whose next attempt is due, oldest first. It stops at the first item that is not due,
+// so a failed item holds back the ones queued after it and only the head of the queue is decoded while it waits.
+func (o *Outbox) Due(now time.Time, limit int) ([]Item, error) {
+	var items []Item
+	err := o.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(pendingBucket).Cursor()
+		for k, v := c.First(); k != nil && len(items) < limit; k, v = c.Next() {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if item.NextAttempt.After(now) {
+				break
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+
+	return items, err
+}
+
+// Ack - remove delivered items
+func (o *Outbox) Ack(ids ...uint64) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pendingBucket)
+		for _, id := range ids {
+			if err := bucket.Delete(itob(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Fail - record a failed attempt and schedule the next one with backoff and jitter,
+// items that used up their attempts move to the dead letters. Returns the ids dead-lettered.
+func (o *Outbox) Fail(items []Item, cause error) ([]uint64, error) {
+	var dead []uint64
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(pendingBucket)
+		for _, item := range items {
+			item.Attempts++
+			item.LastError = cause.Error()
+
+			if o.options.MaxAttempts > 0 && item.Attempts >= o.options.MaxAttempts {
+				if err := pending.Delete(itob(item.ID)); err != nil {
+					return err
+				}
+				if err := putItem(tx.Bucket(deadBucket), item); err != nil {
+					return err
+				}
+				dead = append(dead, item.ID)
+				continue
+			}
+
+			item.NextAttempt = time.Now().Add(o.backoff(item.Attempts))
+			if err := putItem(pending, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return dead, err
+}
+
+// Stats - number of pending items, age of the oldest one and number of dead letters
+func (o *Outbox) Stats() (Stats, error) {
+	var stats Stats
+	err := o.db.View(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(pendingBucket)
+		stats.Depth = pending.Stats().KeyN
+		stats.DeadLetters = tx.Bucket(deadBucket).Stats().KeyN
+
+		if _, v := pending.Cursor().First(); v != nil {
+			var oldest Item
+			if err := json.Unmarshal(v, &oldest); err != nil {
+				return err
+			}
+			stats.OldestAge = time.Since(oldest.Created)
+		}
+		return nil
+	})
+
+	return stats, err
+}
+
+// backoff - exponential delay for the given attempt with equal jitter, so retries spread out
+func (o *Outbox) backoff(attempt int) time.Duration {
+	delay := o.options.BaseDelay
+	for i := 1; i < attempt && delay < o.options.MaxDelay; i++ {
+		delay *= 2
+	}
+	if o.options.MaxDelay > 0 && delay > o.options.MaxDelay {
+		delay = o.options.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// putItem - store an item under its id
+func putItem(bucket *bolt.Bucket, item Item) error {
+	js, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(itob(item.ID), js)
+}
+
+// itob - big endian key so bolt keeps items in id order
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	box, err := Open(path, Options{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+
+	for _, payload := range []string{`{"n":1}`, `{"n":2}`} {
+		if err := box.Enqueue([]byte(payload), "http://localhost:4041/file-endpoint"); err != nil {
+			t.Fatalf("Enqueue returned an error: %v", err)
+		}
+	}
+
+	// pending items survive a restart
+	box.Close()
+	box, err = Open(path, Options{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer box.Close()
+
+	items, err := box.Due(time.Now(), 10)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Expected 2 due items, got %d (%v)", len(items), err)
+	}
+	if items[0].Endpoint != "http://localhost:4041/file-endpoint" {
+		t.Errorf("Expected the endpoint to be kept, got %q", items[0].Endpoint)
+	}
+
+	if err := box.Ack(items[0].ID); err != nil {
+		t.Fatalf("Ack returned an error: %v", err)
+	}
+
+	// the first failure backs off, the item is not due again straight away
+	dead, err := box.Fail(items[1:], errors.New("connection refused"))
+	if err != nil || len(dead) != 0 {
+		t.Fatalf("Expected no dead letters after one attempt, got %v (%v)", dead, err)
+	}
+	if due, _ := box.Due(time.Now(), 10); len(due) != 0 {
+		t.Errorf("Expected no due items during backoff, got %d", len(due))
+	}
+
+	later, _ := box.Due(time.Now().Add(2*time.Hour), 10)
+	if len(later) != 1 || later[0].Attempts != 1 || later[0].LastError != "connection refused" {
+		t.Fatalf("Expected the failed item to be due after the backoff, got %+v", later)
+	}
+
+	// the second failure uses up the attempts
+	dead, err = box.Fail(later, errors.New("connection refused"))
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("Expected the item to be dead-lettered, got %v (%v)", dead, err)
+	}
+
+	stats, err := box.Stats()
+	if err != nil {
+		t.Fatalf("Stats returned an error: %v", err)
+	}
+	if stats.Depth != 0 || stats.DeadLetters != 1 {
+		t.Errorf("Expected depth 0 and 1 dead letter, got %+v", stats)
+	}
+}
+
+func TestOutboxOrder(t *testing.T) {
+	box, err := Open(filepath.Join(t.TempDir(), "outbox.db"), Options{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer box.Close()
+
+	for _, endpoint := range []string{"http://localhost:4041/a", "http://localhost:4041/b", "http://localhost:4041/a"} {
+		if err := box.Enqueue([]byte(`{}`), endpoint); err != nil {
+			t.Fatalf("Enqueue returned an error: %v", err)
+		}
+	}
+
+	items, _ := box.Due(time.Now(), 1)
+	if _, err := box.Fail(items, errors.New("connection refused")); err != nil {
+		t.Fatalf("Fail returned an error: %v", err)
+	}
+
+	// the records after the failed one wait for it
+	if due, _ := box.Due(time.Now(), 10); len(due) != 0 {
+		t.Errorf("Expected the failed record to hold back the later ones, got %d due", len(due))
+	}
+
+	due, _ := box.Due(time.Now().Add(2*time.Hour), 10)
+	if len(due) != 3 || due[0].ID != items[0].ID || due[1].ID > due[2].ID {
+		t.Errorf("Expected all 3 records in order after the backoff, got %+v", due)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	box := &Outbox{options: Options{BaseDelay: time.Second, MaxDelay: 10 * time.Second}}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{10, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		delay := box.backoff(test.attempt)
+		if delay < test.max/2 || delay > test.max {
+			t.Errorf("backoff(%d) = %s; want between %s and %s", test.attempt, delay, test.max/2, test.max)
+		}
+	}
+}
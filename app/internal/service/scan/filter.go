@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFiles - files read from every directory of a target with ignore_files set, in this order
+var IgnoreFiles = []string{".gitignore", ".trackerignore"}
+
+// rule - a gitignore style pattern
+type rule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// parseRule - parse one pattern line, false for blank lines and comments.
+// A pattern without a slash matches at any depth, one with a slash is relative to the directory.
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	line = filepath.ToSlash(line)
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	if line == "" {
+		return rule{}, false
+	}
+	r.pattern = line
+
+	return r, true
+}
+
+// parseRules - parse a list of patterns
+func parseRules(lines []string) []rule {
+	rules := make([]rule, 0, len(lines))
+	for _, line := range lines {
+		if r, ok := parseRule(line); ok {
+			rules = append(rules, r)
+		}
+	}
+
+	return rules
+}
+
+// match - whether the rule matches rel, a slash separated path relative to the rule's directory
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	return matchGlob(r.pattern, rel)
+}
+
+// MatchGlob - match a path against a glob like the include and exclude patterns, ** spans any number of directories
+func MatchGlob(pattern, name string) bool {
+	return matchGlob(filepath.ToSlash(pattern), filepath.ToSlash(name))
+}
+
+// ValidGlob - whether every segment of the glob is a valid pattern
+func ValidGlob(pattern string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchGlob - match a slash separated path against a pattern where ** spans any number of directories
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// dirRules - the rules of the ignore files of a directory, depth is its number of path elements below the target
+type dirRules struct {
+	depth int
+	rules []rule
+}
+
+// readIgnoreFiles - the rules of the ignore files in dir
+func readIgnoreFiles(dir string) []rule {
+	var rules []rule
+	for _, name := range IgnoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(dir, name))...)
+	}
+
+	return rules
+}
+
+// readIgnoreFile - the rules of an ignore file, none when it does not exist or cannot be read
+func readIgnoreFile(file string) []rule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return parseRules(lines)
+}
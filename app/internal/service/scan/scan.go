@@ -0,0 +1,221 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+// Walk - walk root, a directory inside the target, calling fn for every directory within the target's
+// max depth that it does not exclude and every file it tracks. Excluded directories are not descended into.
+func Walk(target config.WatchTarget, root string, fn func(path string, info os.FileInfo) error) error {
+	m := newMatcher(target)
+	if root != target.Path && !m.dirAllowed(filepath.Dir(root)) {
+		return nil
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == target.Path {
+				return fn(path, info)
+			}
+			// the files of a directory at max depth would be below it
+			if target.MaxDepth > 0 && depth(target, path) >= target.MaxDepth {
+				return filepath.SkipDir
+			}
+			if m.excluded(path, true) {
+				return filepath.SkipDir
+			}
+			return fn(path, info)
+		}
+
+		if !m.fileAllowed(path, info) {
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// Match - whether the file at path is tracked by the target. info is read with Lstat when nil,
+// the size filter is skipped if the file is gone.
+func Match(target config.WatchTarget, path string, info os.FileInfo) bool {
+	if !Contains(target, path) || path == target.Path {
+		return false
+	}
+	if target.MaxDepth > 0 && depth(target, path) > target.MaxDepth {
+		return false
+	}
+
+	m := newMatcher(target)
+	if !m.dirAllowed(filepath.Dir(path)) {
+		return false
+	}
+	if info == nil {
+		info, _ = os.Lstat(path)
+	}
+
+	return m.fileAllowed(path, info)
+}
+
+// matcher - the filters of a target with its patterns parsed once, the ignore files are read
+// and their rules gathered once per directory
+type matcher struct {
+	target  config.WatchTarget
+	include []rule
+	exclude []rule
+	ignores map[string][]dirRules
+}
+
+// newMatcher - parse the include and exclude patterns of the target
+func newMatcher(target config.WatchTarget) *matcher {
+	return &matcher{
+		target:  target,
+		include: parseRules(target.Include),
+		exclude: parseRules(target.Exclude),
+		ignores: make(map[string][]dirRules),
+	}
+}
+
+// dirAllowed - whether no directory from the target down to dir is excluded
+func (m *matcher) dirAllowed(dir string) bool {
+	if dir == m.target.Path {
+		return true
+	}
+	if !Contains(m.target, dir) {
+		return false
+	}
+
+	return m.dirAllowed(filepath.Dir(dir)) && !m.excluded(dir, true)
+}
+
+// fileAllowed - include patterns, exclude patterns, ignore files, extensions and size of a file
+func (m *matcher) fileAllowed(path string, info os.FileInfo) bool {
+	if len(m.include) > 0 {
+		included := false
+		for _, r := range m.include {
+			if r.match(relPath(m.target, path), false) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	if m.excluded(path, false) {
+		return false
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if len(m.target.Extensions) > 0 && !hasExtension(m.target.Extensions, ext) {
+		return false
+	}
+	if hasExtension(m.target.ExcludeExtensions, ext) {
+		return false
+	}
+
+	return m.target.MaxFileSize <= 0 || info == nil || info.Size() <= m.target.MaxFileSize
+}
+
+// excluded - whether the exclude patterns or, with ignore_files, the ignore files exclude the path.
+// The last matching rule wins and the rules of deeper ignore files come later, as with .gitignore.
+func (m *matcher) excluded(path string, isDir bool) bool {
+	rel := relPath(m.target, path)
+
+	ignored := false
+	for _, r := range m.exclude {
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	if ignored || !m.target.IgnoreFiles {
+		return ignored
+	}
+
+	parts := strings.Split(rel, "/")
+	for _, d := range m.ignoreRules(filepath.Dir(path)) {
+		for _, r := range d.rules {
+			if r.match(strings.Join(parts[d.depth:], "/"), isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	return ignored
+}
+
+// ignoreRules - the rules of the ignore files from the target down to dir, the target's first
+func (m *matcher) ignoreRules(dir string) []dirRules {
+	if chain, ok := m.ignores[dir]; ok {
+		return chain
+	}
+
+	var chain []dirRules
+	if dir != m.target.Path && Contains(m.target, dir) {
+		chain = m.ignoreRules(filepath.Dir(dir))
+	}
+	if rules := readIgnoreFiles(dir); len(rules) > 0 {
+		// copied so the chains of sibling directories do not share their tail
+		chain = append(append([]dirRules(nil), chain...), dirRules{depth: depth(m.target, dir), rules: rules})
+	}
+	m.ignores[dir] = chain
+
+	return chain
+}
+
+// hasExtension - whether ext is in the list, the dot and case of the listed extensions do not matter
+func hasExtension(extensions []string, ext string) bool {
+	for _, e := range extensions {
+		if "."+strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// relPath - slash separated path below the target
+func relPath(target config.WatchTarget, path string) string {
+	rel, _ := filepath.Rel(target.Path, path)
+	return filepath.ToSlash(rel)
+}
+
+// Contains - whether path is the target directory or below it
+func Contains(target config.WatchTarget, path string) bool {
+	rel, err := filepath.Rel(target.Path, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Owner - the target a path belongs to, the deepest one when targets are nested
+func Owner(targets []config.WatchTarget, path string) (config.WatchTarget, bool) {
+	var owner config.WatchTarget
+	found := false
+	for _, target := range targets {
+		if Contains(target, path) && (!found || len(target.Path) > len(owner.Path)) {
+			owner, found = target, true
+		}
+	}
+
+	return owner, found
+}
+
+// depth - number of path elements of path below the target, 1 for a file directly in it
+func depth(target config.WatchTarget, path string) int {
+	rel, err := filepath.Rel(target.Path, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
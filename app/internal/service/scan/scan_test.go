@@ -0,0 +1,140 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+)
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.tmp", "sub/c.txt", "sub/deep/d.txt", "build/e.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// the root ignore file drops build output and temp files, the one in sub drops deep/
+	if err := os.WriteFile(filepath.Join(dir, ".trackerignore"), []byte("# build output\nbuild/\n*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", ".gitignore"), []byte("deep/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target config.WatchTarget
+		want   []string
+	}{
+		{"everything", config.WatchTarget{Path: dir, Exclude: []string{".*"}}, []string{"a.txt", "b.tmp", "build/e.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{"max depth", config.WatchTarget{Path: dir, MaxDepth: 2, Exclude: []string{".*"}}, []string{"a.txt", "b.tmp", "build/e.txt", "sub/c.txt"}},
+		{"include", config.WatchTarget{Path: dir, Include: []string{"*.txt"}, MaxDepth: 1}, []string{"a.txt"}},
+		{"exclude", config.WatchTarget{Path: dir, Exclude: []string{"*.tmp", "build/*", ".*"}}, []string{"a.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{"double star", config.WatchTarget{Path: dir, Include: []string{"sub/**/*.txt"}}, []string{"sub/c.txt", "sub/deep/d.txt"}},
+		{"negated exclude", config.WatchTarget{Path: dir, Exclude: []string{"*.txt", "!sub/c.txt"}, Extensions: []string{"txt"}}, []string{"sub/c.txt"}},
+		{"ignore files", config.WatchTarget{Path: dir, IgnoreFiles: true, ExcludeExtensions: []string{".gitignore", ".trackerignore"}}, []string{"a.txt", "sub/c.txt"}},
+		{"max file size", config.WatchTarget{Path: dir, MaxFileSize: 5, Exclude: []string{".*"}}, []string{"a.txt", "b.tmp"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Walk(tt.target, dir, func(path string, info os.FileInfo) error {
+				if !info.IsDir() {
+					rel, _ := filepath.Rel(dir, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Walk returned an error: %v", err)
+			}
+
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+// TestIgnoreRules - each directory gathers the ignore rules from the target down once, siblings keep their own
+func TestIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{".trackerignore": "*.log\n", "x/.gitignore": "a.txt\n", "y/.gitignore": "b.txt\n", "x/z/c.txt": "c"}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	m := newMatcher(config.WatchTarget{Path: dir, IgnoreFiles: true})
+	x, y := m.ignoreRules(filepath.Join(dir, "x")), m.ignoreRules(filepath.Join(dir, "y"))
+	z := m.ignoreRules(filepath.Join(dir, "x", "z"))
+	if len(x) != 2 || len(y) != 2 || len(z) != 2 {
+		t.Fatalf("Expected the root and own rules, got %v, %v and %v", x, y, z)
+	}
+	if x[1].rules[0].pattern != "**/a.txt" || y[1].rules[0].pattern != "**/b.txt" || z[1].depth != 1 {
+		t.Errorf("Expected every directory to keep its own rules, got %v, %v and %v", x, y, z)
+	}
+
+	for name, want := range map[string]bool{"x/a.txt": true, "x/z/a.txt": true, "y/a.txt": false, "y/b.log": true} {
+		if got := m.excluded(filepath.Join(dir, filepath.FromSlash(name)), false); got != want {
+			t.Errorf("%s: got excluded %v; want %v", name, got, want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/*.swp", "a.swp", true},
+		{"**/*.swp", "x/y/a.swp", true},
+		{"build/**", "build/out/app", true},
+		{"src/**/test/*.go", "src/test/a.go", true},
+		{"src/**/test/*.go", "src/a/b/test/a.go", true},
+		{"src/**/test/*.go", "src/a/b/a.go", false},
+		{"docs/*.md", "docs/a/b.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOwner(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	nested := filepath.FromSlash("/data/projects")
+	targets := []config.WatchTarget{{Path: nested}, {Path: root}}
+
+	if owner, ok := Owner(targets, filepath.FromSlash("/data/projects/app/main.go")); !ok || owner.Path != nested {
+		t.Errorf("Expected the nested target to own the file, got %q", owner.Path)
+	}
+	if owner, ok := Owner(targets, filepath.FromSlash("/data/notes.txt")); !ok || owner.Path != root {
+		t.Errorf("Expected the outer target to own the file, got %q", owner.Path)
+	}
+	if _, ok := Owner(targets, filepath.FromSlash("/database/x")); ok {
+		t.Error("Expected no owner for a path outside every target")
+	}
+}
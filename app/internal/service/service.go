@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"github.com/thespider911/filetrackermodification/app/internal/config"
+	"github.com/thespider911/filetrackermodification/app/internal/osquery"
 	"github.com/thespider911/filetrackermodification/app/internal/service/command"
 	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
 	"log"
@@ -16,12 +18,19 @@ var ErrNoFile = errors.New("models: no such existing file record found")
 type Service struct {
 	FileTracker    filetrack.FileTracker
 	CommandRunFile command.CommandRunFile
+	Osquery        *osquery.Client
 }
 
-func NewService() Service {
+// NewService - create the services using the backends chosen in config,
+// osquery is queried over the extension socket, or with osqueryi when socket is empty,
+// and the history of a path comes from the scans stored in history
+func NewService(cfg config.Config, socket string, history command.History) Service {
+	client := osquery.NewClient(socket)
+
 	return Service{
-		FileTracker:    filetrack.NewFileTracker(),
-		CommandRunFile: command.NewCommandFileInfo(),
+		FileTracker:    filetrack.NewFileTracker(cfg.TrackerBackend, cfg.HashAlgorithm, client),
+		CommandRunFile: command.NewCommandFileInfo(cfg, client, history),
+		Osquery:        client,
 	}
 }
 
@@ -0,0 +1,226 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+)
+
+// EventType - kind of change found between two scans of a path
+type EventType string
+
+const (
+	Created           EventType = "created"
+	ContentModified   EventType = "content_modified"
+	MetadataChanged   EventType = "metadata_changed"
+	PermissionChanged EventType = "permission_changed"
+	Deleted           EventType = "deleted"
+	Renamed           EventType = "renamed"
+
+	// the content hash no longer matches the approved baseline
+	IntegrityViolation EventType = "integrity_violation"
+)
+
+// Event - a change to a single path, the embedded FileInfo is the latest known state
+type Event struct {
+	Type    EventType `json:"event"`
+	Time    time.Time `json:"event_time"`
+	OldPath string    `json:"old_path,omitempty"`
+	filetrack.FileInfo
+	Previous     *filetrack.FileInfo `json:"previous,omitempty"`
+	ExpectedHash string              `json:"expected_hash,omitempty"`
+}
+
+// tombstone - a path reported removed, kept for a short time in case it reappears under a new name
+type tombstone struct {
+	info    filetrack.FileInfo
+	removed time.Time
+}
+
+// Store - last known FileInfo of every tracked path, indexed by path and inode
+type Store struct {
+	mu      sync.Mutex
+	files   map[string]filetrack.FileInfo
+	inodes  map[string]string
+	removed map[string]tombstone
+	primed  bool
+}
+
+// NewStore - new empty snapshot store
+func NewStore() *Store {
+	return &Store{
+		files:   make(map[string]filetrack.FileInfo),
+		inodes:  make(map[string]string),
+		removed: make(map[string]tombstone),
+	}
+}
+
+// Seed - restore the last known state of paths, e.g. from the event store after a restart,
+// so changes made while the service was down are reported on the first scan
+func (s *Store) Seed(infos []filetrack.FileInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, info := range infos {
+		s.put(info)
+	}
+	if len(infos) > 0 {
+		s.primed = true
+	}
+}
+
+// Compare - record the current info of a path and return what changed since the previous scan.
+// Until the first full scan has been swept, new paths are recorded without a created event.
+func (s *Store) Compare(info filetrack.FileInfo) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	previous, known := s.files[info.Path]
+	s.put(info)
+
+	if !known {
+		if old, ok := s.renamedFrom(info); ok {
+			return []Event{{Type: Renamed, Time: now, OldPath: old.Path, FileInfo: info, Previous: &old}}
+		}
+		if !s.primed {
+			return nil
+		}
+		return []Event{{Type: Created, Time: now, FileInfo: info}}
+	}
+
+	var events []Event
+	if info.FileSize != previous.FileSize || info.ModifiedTime != previous.ModifiedTime || info.Hash != previous.Hash {
+		events = append(events, Event{Type: ContentModified, Time: now, FileInfo: info, Previous: &previous})
+	}
+	if info.Permission != previous.Permission || info.Uid != previous.Uid {
+		events = append(events, Event{Type: PermissionChanged, Time: now, FileInfo: info, Previous: &previous})
+	}
+	if len(events) == 0 && (info.ChangedTime != previous.ChangedTime || info.FileType != previous.FileType) {
+		events = append(events, Event{Type: MetadataChanged, Time: now, FileInfo: info, Previous: &previous})
+	}
+
+	return events
+}
+
+// Remove - mark a path and everything below it as removed, the deleted events are
+// returned by Flush unless the inode shows up again under a new name first
+func (s *Store) Remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for known, info := range s.files {
+		if known == path || isBelow(known, path) {
+			s.drop(known)
+			s.removed[known] = tombstone{info: info, removed: now}
+		}
+	}
+}
+
+// Flush - deleted events for removed paths older than grace that were not renamed
+func (s *Store) Flush(grace time.Duration) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []Event
+	now := time.Now()
+	for path, stone := range s.removed {
+		if now.Sub(stone.removed) < grace {
+			continue
+		}
+		delete(s.removed, path)
+		events = append(events, Event{Type: Deleted, Time: now, FileInfo: stone.info})
+	}
+
+	return events
+}
+
+// Sweep - after a full walk of root, delete every known path below it that was not seen
+func (s *Store) Sweep(root string, seen map[string]struct{}) []Event {
+	return s.SweepFunc(func(path string) bool { return isBelow(path, root) }, seen)
+}
+
+// SweepFunc - after a full walk, delete every known path the walk owns that was not seen
+func (s *Store) SweepFunc(owns func(path string) bool, seen map[string]struct{}) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []Event
+	now := time.Now()
+	for path, info := range s.files {
+		if _, ok := seen[path]; ok || !owns(path) {
+			continue
+		}
+		s.drop(path)
+		events = append(events, Event{Type: Deleted, Time: now, FileInfo: info})
+	}
+	s.primed = true
+
+	return events
+}
+
+// Get - last known info of a path
+func (s *Store) Get(path string) (filetrack.FileInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.files[path]
+	return info, ok
+}
+
+// renamedFrom - find the old entry of a path that is new to the store by its inode,
+// either a removed path or a known path that is no longer on disk
+func (s *Store) renamedFrom(info filetrack.FileInfo) (filetrack.FileInfo, bool) {
+	if info.Inode == "" || info.Inode == "0" {
+		return filetrack.FileInfo{}, false
+	}
+
+	for path, stone := range s.removed {
+		if stone.info.Inode == info.Inode {
+			delete(s.removed, path)
+			return stone.info, true
+		}
+	}
+
+	oldPath, ok := s.inodes[info.Inode]
+	if !ok || oldPath == info.Path {
+		return filetrack.FileInfo{}, false
+	}
+	if _, err := os.Lstat(oldPath); !os.IsNotExist(err) {
+		return filetrack.FileInfo{}, false
+	}
+
+	old := s.files[oldPath]
+	s.drop(oldPath)
+	s.inodes[info.Inode] = info.Path
+	return old, true
+}
+
+// put - store info and index its inode
+func (s *Store) put(info filetrack.FileInfo) {
+	s.files[info.Path] = info
+	if info.Inode != "" && info.Inode != "0" {
+		if _, ok := s.inodes[info.Inode]; !ok {
+			s.inodes[info.Inode] = info.Path
+		}
+	}
+}
+
+// drop - forget a path and its inode index
+func (s *Store) drop(path string) {
+	info := s.files[path]
+	delete(s.files, path)
+	if s.inodes[info.Inode] == path {
+		delete(s.inodes, info.Inode)
+	}
+}
+
+// isBelow - check if path is inside dir
+func isBelow(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,89 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thespider911/filetrackermodification/app/internal/service/filetrack"
+)
+
+func TestCompare(t *testing.T) {
+	store := NewStore()
+	dir := t.TempDir()
+	file := filetrack.FileInfo{Path: filepath.Join(dir, "a.txt"), FileSize: "10", ModifiedTime: "100", Permission: "0644", Inode: "1"}
+
+	// first scan only primes the store
+	if events := store.Compare(file); len(events) != 0 {
+		t.Fatalf("Expected no events before the first sweep, got %v", events)
+	}
+	store.Sweep(dir, map[string]struct{}{file.Path: {}})
+
+	tests := []struct {
+		name     string
+		change   func(filetrack.FileInfo) filetrack.FileInfo
+		expected []EventType
+	}{
+		{"unchanged", func(f filetrack.FileInfo) filetrack.FileInfo { return f }, nil},
+		{"content", func(f filetrack.FileInfo) filetrack.FileInfo { f.FileSize = "20"; return f }, []EventType{ContentModified}},
+		{"permission", func(f filetrack.FileInfo) filetrack.FileInfo { f.Permission = "0600"; return f }, []EventType{PermissionChanged}},
+		{"metadata", func(f filetrack.FileInfo) filetrack.FileInfo { f.ChangedTime = "200"; return f }, []EventType{MetadataChanged}},
+		{"content and permission", func(f filetrack.FileInfo) filetrack.FileInfo {
+			f.ModifiedTime = "300"
+			f.Permission = "0755"
+			return f
+		}, []EventType{ContentModified, PermissionChanged}},
+	}
+
+	for _, test := range tests {
+		file = test.change(file)
+		events := store.Compare(file)
+		if len(events) != len(test.expected) {
+			t.Errorf("%s: got %d events; want %v", test.name, len(events), test.expected)
+			continue
+		}
+		for i, event := range events {
+			if event.Type != test.expected[i] {
+				t.Errorf("%s: event %d = %s; want %s", test.name, i, event.Type, test.expected[i])
+			}
+		}
+	}
+
+	created := filetrack.FileInfo{Path: filepath.Join(dir, "b.txt"), Inode: "2"}
+	if events := store.Compare(created); len(events) != 1 || events[0].Type != Created {
+		t.Errorf("Expected a created event after the first sweep, got %v", events)
+	}
+}
+
+func TestRenameAndDelete(t *testing.T) {
+	store := NewStore()
+	dir := t.TempDir()
+	oldFile := filetrack.FileInfo{Path: filepath.Join(dir, "old.txt"), Inode: "7"}
+	otherFile := filetrack.FileInfo{Path: filepath.Join(dir, "other.txt"), Inode: "8"}
+	store.Compare(oldFile)
+	store.Compare(otherFile)
+	store.Sweep(dir, map[string]struct{}{oldFile.Path: {}, otherFile.Path: {}})
+
+	// the old path does not exist on disk, so the same inode under a new name is a rename
+	newFile := oldFile
+	newFile.Path = filepath.Join(dir, "new.txt")
+	events := store.Compare(newFile)
+	if len(events) != 1 || events[0].Type != Renamed || events[0].OldPath != oldFile.Path {
+		t.Fatalf("Expected a renamed event from %s, got %v", oldFile.Path, events)
+	}
+
+	// a removed path is reported deleted once the grace period is over
+	store.Remove(otherFile.Path)
+	if events := store.Flush(time.Hour); len(events) != 0 {
+		t.Errorf("Expected no deleted events inside the grace period, got %v", events)
+	}
+	if events := store.Flush(0); len(events) != 1 || events[0].Type != Deleted || events[0].Path != otherFile.Path {
+		t.Errorf("Expected a deleted event for %s, got %v", otherFile.Path, events)
+	}
+
+	// paths missing from a full scan are deleted by the sweep
+	events = store.Sweep(dir, map[string]struct{}{})
+	if len(events) != 1 || events[0].Type != Deleted || events[0].Path != newFile.Path {
+		t.Errorf("Expected a deleted event for %s, got %v", newFile.Path, events)
+	}
+}
@@ -0,0 +1,57 @@
+package tlsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "server.crt"), filepath.Join(dir, "tls", "server.key")
+
+	generated, err := EnsureSelfSigned(certFile, keyFile)
+	if err != nil || !generated {
+		t.Fatalf("Expected a new self-signed pair, got %v (%v)", generated, err)
+	}
+
+	// existing files are left alone
+	if generated, err := EnsureSelfSigned(certFile, keyFile); err != nil || generated {
+		t.Fatalf("Expected the existing pair to be kept, got %v (%v)", generated, err)
+	}
+
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader returned an error: %v", err)
+	}
+	first, _ := reloader.GetCertificate(nil)
+	if first == nil || len(first.Certificate) == 0 {
+		t.Fatal("Expected a certificate")
+	}
+
+	// replace the pair with a newer one
+	os.Remove(certFile)
+	os.Remove(keyFile)
+	if _, err := EnsureSelfSigned(certFile, keyFile); err != nil {
+		t.Fatalf("EnsureSelfSigned returned an error: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	// force the next handshake to check the files
+	reloader.lastCheck = time.Time{}
+	second, _ := reloader.GetCertificate(nil)
+	if string(second.Certificate[0]) == string(first.Certificate[0]) {
+		t.Error("Expected the new certificate after the files changed")
+	}
+
+	// a broken pair keeps the last good certificate
+	os.WriteFile(certFile, []byte("not a certificate"), 0644)
+	os.Chtimes(certFile, future.Add(time.Minute), future.Add(time.Minute))
+	reloader.lastCheck = time.Time{}
+	third, _ := reloader.GetCertificate(nil)
+	if string(third.Certificate[0]) != string(second.Certificate[0]) {
+		t.Error("Expected the last good certificate to be kept")
+	}
+}
@@ -1,17 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 )
 
 type FileInfo struct {
+	Event     string `json:"event"`
+	EventTime string `json:"event_time"`
+	OldPath   string `json:"old_path,omitempty"`
 	UUID      string `json:"uid"`
 	Path      string `json:"path"`
 	Directory string `json:"directory"`
@@ -22,12 +35,18 @@ type FileInfo struct {
 	Size      string `json:"size"`
 	Type      string `json:"type"`
 	Mode      string `json:"mode"`
+	Inode     string `json:"inode"`
 }
 
 type Config struct {
-	APIPort int `mapstructure:"api_port" validate:"required,min=4041,max=4045"`
+	APIPort       int    `mapstructure:"api_port" validate:"required,min=4041,max=4045"`
+	APIToken      string `mapstructure:"api_token"`
+	APIHMACSecret string `mapstructure:"api_hmac_secret"`
 }
 
+// signatures older or newer than this are rejected as replays
+const signatureTolerance = 5 * time.Minute
+
 type App struct {
 	config       Config
 	receivedData []FileInfo
@@ -88,21 +107,104 @@ func (app *App) handleFileUpdates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//decode file info data
-	var fileInfo FileInfo
-	if err := json.NewDecoder(r.Body).Decode(&fileInfo); err != nil {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//reject requests without the configured token or signature
+	if err := app.authenticate(r, data, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	//decode file info data, a single record, a json array batch or ndjson
+	fileInfos, err := decodeFileInfos(r, data)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	app.dataMutex.Lock()
-	app.receivedData = append(app.receivedData, fileInfo)
+	app.receivedData = append(app.receivedData, fileInfos...)
 	app.dataMutex.Unlock()
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Data received successfully"))
 }
 
+// authenticate - check the bearer token and the hmac signature of the body when they are configured
+func (app *App) authenticate(r *http.Request, body []byte, now time.Time) error {
+	if app.config.APIToken != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.config.APIToken)) != 1 {
+			return fmt.Errorf("invalid bearer token")
+		}
+	}
+
+	if app.config.APIHMACSecret != "" {
+		timestamp := r.Header.Get("X-Signature-Timestamp")
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("missing signature timestamp")
+		}
+
+		age := now.Sub(time.Unix(unix, 0))
+		if age > signatureTolerance || age < -signatureTolerance {
+			return fmt.Errorf("signature timestamp outside the allowed window")
+		}
+
+		mac := hmac.New(sha256.New, []byte(app.config.APIHMACSecret))
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(expected)) {
+			return fmt.Errorf("invalid signature")
+		}
+	}
+
+	return nil
+}
+
+// decodeFileInfos - read one or many records from the body, gunzipping it when needed
+func decodeFileInfos(r *http.Request, data []byte) ([]FileInfo, error) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	data = bytes.TrimSpace(data)
+
+	var fileInfos []FileInfo
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &fileInfos); err != nil {
+			return nil, err
+		}
+		return fileInfos, nil
+	}
+
+	// a single object or one object per line
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var fileInfo FileInfo
+		if err := decoder.Decode(&fileInfo); err != nil {
+			return nil, err
+		}
+		fileInfos = append(fileInfos, fileInfo)
+	}
+
+	return fileInfos, nil
+}
+
 func (app *App) viewData(w http.ResponseWriter, r *http.Request) {
 	app.dataMutex.RLock()
 	defer app.dataMutex.RUnlock()